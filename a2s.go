@@ -1,155 +1,440 @@
+//go:build !js
+
 package a2s
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"math"
+	"io"
+	"log/slog"
+	"math/rand/v2"
 	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 
-const (
-	Header        = 0xFFFFFFFF
-	SPLIT_FLAG    = 0xFFFFFFFE
-	A2S_INFO      = 0x54
-	A2S_PLAYER    = 0x55
-	A2S_RULES     = 0x56
-	A2S_CHALLENGE = 0x41
-	S2C_CHALLENGE = 0x41
-	S2A_INFO_SRC  = 0x49
-	S2A_INFO_GOLD = 0x6D
-	S2A_PLAYER    = 0x44
-	S2A_RULES     = 0x45
-)
-
-
 type Client struct {
-	conn      *net.UDPConn
+	conn      net.Conn
 	challenge int32
 	timeout   time.Duration
 	connected bool
+	// closed is set by Close, which may be called from another goroutine
+	// while a query is in flight. connMu guards conn, and the conns of a
+	// Happy Eyeballs race in racing, against Close and the cancellation of
+	// a context while the query switches them.
+	closed    atomic.Bool
+	connMu    sync.Mutex
+	racing    []net.Conn
+	sortRules bool
+	lastRTT   time.Duration
+	lastSize  int
+	lastSplit *SplitInfo
+	// checksum is the worst checksum status seen since the start of the
+	// current QueryAll.
+	checksum     ChecksumStatus
+	skipChecksum bool
+	decoder   Decoder
+
+	legacyPing      bool
+	legacyFallback  bool
+	pingSupported   bool
+	noInfoChallenge bool
+
+	// goldSource is set once the server answered with a GoldSource info
+	// response, legacyQueries once it answered a pre-Steam text query.
+	goldSource    bool
+	legacyQueries bool
+
+	srtt            time.Duration
+	adaptiveFactor  float64
+	adaptiveMinimum time.Duration
+
+	budget         DeadlineBudget
+	budgetDeadline time.Time
+	// operations counts the nested public operations in progress.
+	operations int
+	// ctx is the context of a GetInfoContext and similar call in progress.
+	ctx context.Context
+
+	iface      string
+	localAddr  netip.AddrPort
+	socketOpts SocketOptions
+	resolver   *net.Resolver
+	srvService string
+	sources    *SourcePool
+	socks      *socksProxy
+	dialer     DialFunc
+	// host is the address given to Connect, resolved again as
+	// WithReresolve asks for.
+	host              string
+	resolvedAt        time.Time
+	resolveStale      bool
+	reresolveInterval time.Duration
+	// candidates are the alternative addresses raced by Happy Eyeballs
+	// until one of them or the connected one answers.
+	candidates    []netip.AddrPort
+	eyeballsDelay time.Duration
+
+	limiter *RateLimiter
+	breaker *CircuitBreaker
+	retry   RetryPolicy
+	checks  validators
+	hooks   transforms
+	// metadata is attached by the user and carried into snapshots.
+	metadata map[string]any
+	// challenges is shared between clients, cachedChallenge is set while
+	// the challenge of the client came from it and was not yet confirmed.
+	challenges      *ChallengeCache
+	cachedChallenge bool
+	clock   Clock
+	random  *rand.Rand
+
+	tracer Tracer
+	scope  *traceScope
+	logger *slog.Logger
 }
 
-func NewClient(timeout time.Duration) *Client {
-	return &Client{
+func NewClient(timeout time.Duration, opts ...Option) *Client {
+	c := &Client{
 		timeout:   timeout,
 		challenge: -1,
+		retry:     DefaultRetryPolicy,
+		clock:     SystemClock,
+
+		reresolveInterval: -1,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 
+// Connect resolves the address and connects to it. See ConnectAddrPort.
 func (c *Client) Connect(addr string) error {
-	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	return c.ConnectContext(context.Background(), addr)
+}
+
+// ConnectContext is like Connect, but resolving the address is canceled
+// when ctx is done. With WithDeadlineBudget, resolving is limited to the
+// resolve share of the budget as well, or to its total if the resolve phase
+// has no share.
+func (c *Client) ConnectContext(ctx context.Context, addr string) (err error) {
+	scope := c.trace(SpanConnect)
+	defer func() { scope.end(err) }()
+
+	defer c.startBudget()()
+	if timeout, ok := c.resolveTimeout(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	addrPorts, err := c.resolveAddrPorts(ctx, addr)
 	if err != nil {
 		return err
 	}
+	if err := c.dial(addrPorts[0]); err != nil {
+		return err
+	}
+	if c.eyeballsDelay > 0 {
+		c.candidates = addrPorts[1:]
+	}
+	c.host = addr
+	c.resolvedAt = c.clock.Now()
+	return nil
+}
+
+// ConnectAddrPort dials the server at the given address. No packets are sent
+// until the first query.
+func (c *Client) ConnectAddrPort(addr netip.AddrPort) (err error) {
+	scope := c.trace(SpanConnect)
+	defer func() { scope.end(err) }()
+
+	return c.dial(addr)
+}
 
-	conn, err := net.DialUDP("udp", nil, udpAddr)
+func (c *Client) dial(addr netip.AddrPort) error {
+	conn, err := c.dialUDP(addr)
 	if err != nil {
 		return err
 	}
 
+	c.candidates = nil
+	c.host = ""
+	c.connMu.Lock()
 	c.conn = conn
+	c.connMu.Unlock()
 	c.connected = true
+	c.closed.Store(false)
+	c.trackLeak()
+	if c.scope != nil {
+		c.scope.span.SetAttributes(c.serverAttributes()...)
+	}
 	return conn.SetDeadline(time.Now().Add(c.timeout))
 }
 
+var _ io.Closer = (*Client)(nil)
+
+// Close closes the connection. A query in flight on another goroutine is
+// unblocked immediately and fails with ErrClientClosed, as does every query
+// made afterwards until the client is connected again. Closing a closed
+// client does nothing.
 func (c *Client) Close() error {
-	if c.conn != nil {
-		c.connected = false
-		return c.conn.Close()
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	if c.conn == nil || c.closed.Swap(true) {
+		return nil
 	}
-	return nil
+	for _, conn := range c.racing {
+		if conn != c.conn {
+			conn.Close()
+		}
+	}
+	return c.conn.Close()
+}
+
+// Metadata returns the metadata attached with WithMetadata, or nil. The map
+// is shared with the snapshots of the client, not copied.
+func (c *Client) Metadata() map[string]any {
+	return c.metadata
 }
 
 func (c *Client) IsConnected() bool {
-	return c.connected && c.conn != nil
+	return c.connected && c.conn != nil && !c.closed.Load()
+}
+
+// checkConnected returns ErrClientClosed if the client has been closed,
+// ErrNotConnected if it has not been connected yet and ErrCircuitOpen if the
+// circuit breaker does not let queries to the server through. With
+// WithReresolve, it re-dials first if the address of the server changed.
+func (c *Client) checkConnected() error {
+	if c.closed.Load() {
+		return ErrClientClosed
+	}
+	if !c.IsConnected() {
+		return ErrNotConnected
+	}
+	c.reresolve()
+	if c.breaker != nil {
+		if addr, ok := c.remoteAddrPort(); ok && !c.breaker.allow(addr, c.clock.Now()) {
+			return ErrCircuitOpen
+		}
+	}
+	return nil
 }
 
 
 // GetInfo gets the server info. It sends an A2S_INFO request to the server and
 // parses the response. If the response is from a GoldSource server, it uses
 // parseGoldSourceInfo to parse the response. Otherwise, it uses parseSourceInfo.
-// If the server is not connected, it returns an ErrNotConnected error.
-func (c *Client) GetInfo() (*ServerInfo, error) {
-	if !c.IsConnected() {
-		return nil, ErrNotConnected
+// If a server known to run GoldSource, or any server with WithLegacyQueries,
+// does not answer at all, the pre-Steam "details" query is tried before
+// giving up, see getLegacyInfo.
+// The AppID of Source servers is remembered to parse game specific player data.
+// If the client is not connected, it returns ErrNotConnected, or
+// ErrClientClosed once it has been closed.
+func (c *Client) GetInfo() (info *ServerInfo, err error) {
+	scope := c.trace(SpanGetInfo, Attribute{AttrQuery, "info"})
+	defer func() { scope.end(err) }()
+	defer c.startBudget()()
+	defer func() {
+		if err == nil {
+			err = validate(c.checks.info, info)
+		}
+		if err == nil {
+			info = transform(c.hooks.info, info)
+		}
+	}()
+
+	if err := c.checkConnected(); err != nil {
+		return nil, err
 	}
 
 	payload := []byte{0x53, 0x6F, 0x75, 0x72, 0x63, 0x65, 0x20, 0x45, 0x6E, 0x67, 0x69, 0x6E, 0x65, 0x20, 0x51, 0x75, 0x65, 0x72, 0x79, 0x00}
 	
 	response, err := c.sendRequest(A2S_INFO, payload, S2A_INFO_SRC)
 	if err != nil {
+		if errors.Is(err, ErrTimeout) {
+			if !c.goldSource && !c.legacyFallback {
+				return nil, err
+			}
+			scope.retried()
+			return c.getLegacyInfo(err)
+		}
+		scope.retried()
 		response, err = c.sendRequest(A2S_INFO, payload, S2A_INFO_GOLD)
 		if err != nil {
 			return nil, err
 		}
-		return c.parseGoldSourceInfo(response)
+		c.goldSource = true
+		return c.decoder.parseGoldSourceInfo(response)
+	}
+
+	info, err = c.decoder.parseSourceInfo(response)
+	if err != nil {
+		return nil, err
 	}
-	return c.parseSourceInfo(response)
+	c.decoder.AppID = info.AppID
+	return info, nil
 }
 
 
-func (c *Client) GetPlayers() ([]PlayerInfo, error) {
-	if !c.IsConnected() {
-		return nil, ErrNotConnected
-	}
+func (c *Client) GetPlayers() (players []PlayerInfo, err error) {
+	scope := c.trace(SpanPlayers, Attribute{AttrQuery, "players"})
+	defer func() { scope.end(err) }()
+	defer c.startBudget()()
 
-	challengeReq := []byte{0xFF, 0xFF, 0xFF, 0xFF}
-	_, err := c.sendRequest(A2S_PLAYER, challengeReq, S2C_CHALLENGE)
-	if err != nil && !errors.Is(err, ErrChallengeRequired) {
+	if err := c.checkConnected(); err != nil {
 		return nil, err
 	}
-	
-	if c.challenge == -1 {
-		return nil, errors.New("challenge not received")
-	}
 
-	challengeBytes := make([]byte, 4)
-	binary.LittleEndian.PutUint32(challengeBytes, uint32(c.challenge))
-	
-	response, err := c.sendRequest(A2S_PLAYER, challengeBytes, S2A_PLAYER)
+	response, err := c.requestWithLegacyFallback(A2S_PLAYER, legacyPlayersQuery, S2A_PLAYER)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.parsePlayersResponse(response)
+	players, err = c.decoder.parsePlayersResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(c.checks.players, players); err != nil {
+		return nil, err
+	}
+	return transform(c.hooks.players, players), nil
 }
 
 
-func (c *Client) GetRules() ([]Rule, error) {
-	if !c.IsConnected() {
-		return nil, ErrNotConnected
+func (c *Client) GetRules() (_ Rules, err error) {
+	scope := c.trace(SpanRules, Attribute{AttrQuery, "rules"})
+	defer func() { scope.end(err) }()
+	defer c.startBudget()()
+
+	if err := c.checkConnected(); err != nil {
+		return nil, err
 	}
 
-	challengeReq := []byte{0xFF, 0xFF, 0xFF, 0xFF}
-	_, err := c.sendRequest(A2S_RULES, challengeReq, S2C_CHALLENGE)
-	if err != nil && !errors.Is(err, ErrChallengeRequired) {
+	response, err := c.requestWithLegacyFallback(A2S_RULES, legacyRulesQuery, S2A_RULES)
+	if err != nil {
 		return nil, err
 	}
-	
-	if c.challenge == -1 {
-		return nil, errors.New("challenge not received")
+
+	rules, err := c.decoder.parseRulesResponse(response)
+	if err != nil {
+		return nil, err
 	}
 
-	challengeBytes := make([]byte, 4)
-	binary.LittleEndian.PutUint32(challengeBytes, uint32(c.challenge))
-	
-	response, err := c.sendRequest(A2S_RULES, challengeBytes, S2A_RULES)
+	if c.sortRules {
+		slices.SortStableFunc(rules, func(a, b Rule) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+	}
+	if err := validate(c.checks.rules, Rules(rules)); err != nil {
+		return nil, err
+	}
+	return transform(c.hooks.rules, Rules(rules)), nil
+}
+
+
+// QueryAll gathers the server info, players and rules in one call and returns
+// them as a ServerSnapshot. The challenge obtained for the players request is
+// reused for the rules request, saving a round trip. RTT is the round-trip time
+// of the final A2S_INFO exchange. Checksum tells whether any of the responses
+// was compressed and whether its checksum matched.
+// If the info request fails, QueryAll returns the error and no snapshot. Many
+// servers disable the players or rules queries, so failures of those are
+// returned together with a snapshot holding everything else that was gathered.
+func (c *Client) QueryAll() (_ *ServerSnapshot, err error) {
+	scope := c.trace(SpanQueryAll, Attribute{AttrQuery, "all"})
+	defer func() { scope.end(err) }()
+	defer c.startBudget()()
+
+	c.checksum = ChecksumNone
+	info, err := c.GetInfo()
 	if err != nil {
 		return nil, err
 	}
 
-	return c.parseRulesResponse(response)
+	snapshot := &ServerSnapshot{
+		Info:      info,
+		RTT:       c.lastRTT,
+		Timestamp: c.clock.Now(),
+		Metadata:  c.metadata,
+	}
+	addr, _ := c.remoteAddrPort()
+	snapshot.Key = NewServerKey(info, addr)
+
+	var errs []error
+	if snapshot.Players, err = c.GetPlayers(); err != nil {
+		errs = append(errs, fmt.Errorf("players: %w", err))
+	}
+	if snapshot.Rules, err = c.GetRules(); err != nil {
+		errs = append(errs, fmt.Errorf("rules: %w", err))
+	}
+	snapshot.Checksum = c.checksum
+
+	return transform(c.hooks.snapshot, snapshot), errors.Join(errs...)
+}
+
+
+// Ping sends the deprecated A2A_PING request and returns how long it took for
+// the A2A_ACK reply to arrive. Only GoldSource and old Source servers still
+// answer it; when no reply arrives, Ping returns ErrTimeout. Ping can always be
+// called explicitly, but CheckFeatures only probes it with WithLegacyPing.
+func (c *Client) Ping() (_ time.Duration, err error) {
+	scope := c.trace(SpanPing, Attribute{AttrQuery, "ping"})
+	defer func() { scope.end(err) }()
+	defer c.startBudget()()
+
+	if err := c.checkConnected(); err != nil {
+		return 0, err
+	}
+
+	start := c.clock.Now()
+	if _, err := c.sendRequestRaw(A2S_PING, nil, S2A_PING); err != nil {
+		return 0, err
+	}
+	c.pingSupported = true
+	return c.clock.Now().Sub(start), nil
+}
+
+// RTT measures the round-trip time to the server. If the server has answered
+// A2A_PING before (through Ping, or CheckFeatures with WithLegacyPing), the
+// lightweight ping is used. Otherwise an A2S_INFO request is sent and the time
+// of its final exchange is returned, so a challenge round trip is not counted.
+func (c *Client) RTT() (time.Duration, error) {
+	if err := c.checkConnected(); err != nil {
+		return 0, err
+	}
+
+	if c.pingSupported {
+		if rtt, err := c.Ping(); err == nil {
+			return rtt, nil
+		}
+		c.pingSupported = false
+	}
+
+	if _, err := c.GetInfo(); err != nil {
+		return 0, err
+	}
+	return c.lastRTT, nil
 }
 
 
 // CheckFeatures returns the features supported by the server. It checks if the server
-// supports the A2S_PLAYER and A2S_RULES requests and returns a ServerFeatures struct
-// with the appropriate fields set to true or false. The Info field is always set to
-// true, as the A2S_INFO request is always supported.
+// supports the A2S_PLAYER and A2S_RULES requests and returns a ServerFeatures
+// struct with the appropriate fields set to true or false. The Info field is always set
+// to true, as the A2S_INFO request is always supported.
+// Modern servers silently drop A2A_PING, which would cost a full timeout, so the
+// Ping field is only probed with WithLegacyPing. Otherwise it is true only if the
+// server has answered Ping before.
 func (c *Client) CheckFeatures() ServerFeatures {
 	features := ServerFeatures{
 		Info: true,
@@ -162,26 +447,53 @@ func (c *Client) CheckFeatures() ServerFeatures {
 	_, err = c.GetRules()
 	features.Rules = err == nil
 
+	if c.legacyPing {
+		_, err = c.Ping()
+		features.Ping = err == nil
+	} else {
+		features.Ping = c.pingSupported
+	}
+
 	return features
 }
 
 
-// sendRequest sends a request to the server and waits for a response.
-// It retries up to 3 times if the response is a challenge.
-// If the response is not what was expected, it returns an error.
-func (c *Client) sendRequest(packetType byte, payload []byte, expectResponse byte) ([]byte, error) {
-	for retry := 0; retry < 3; retry++ {
-		response, err := c.sendRequestRaw(packetType, payload, expectResponse)
-		if err != nil {
-			if errors.Is(err, ErrChallengeRequired) {
-				time.Sleep(100 * time.Millisecond)
-				continue
-			}
-			return nil, err
-		}
+// requestWithChallenge sends a challenge-protected request (A2S_PLAYER or A2S_RULES).
+// The cached challenge, or -1 if none is known yet, is sent with the request and
+// sendRequest retries once the server hands out a new one. Some older GoldSource
+// servers and mods never answer the -1 probe; for those the challenge is fetched
+// with the dedicated A2S_SERVERQUERY_GETCHALLENGE request and the request is sent again,
+// as it is when a challenge taken from the ChallengeCache goes unanswered.
+func (c *Client) requestWithChallenge(packetType byte, expectResponse byte) ([]byte, error) {
+	c.loadChallenge()
+	response, err := c.sendRequest(packetType, nil, expectResponse)
+	if err == nil {
+		c.cachedChallenge = false
 		return response, nil
 	}
-	return nil, ErrTooManyRetries
+	if !errors.Is(err, ErrTimeout) {
+		return response, err
+	}
+	c.dropCachedChallenge()
+	if c.challenge != -1 {
+		return response, err
+	}
+
+	if err := c.getChallenge(); err != nil {
+		return nil, err
+	}
+	c.scope.retried()
+	return c.sendRequest(packetType, nil, expectResponse)
+}
+
+// getChallenge asks the server for a challenge number with the
+// A2S_SERVERQUERY_GETCHALLENGE request and caches it.
+func (c *Client) getChallenge() error {
+	_, err := c.sendRequestRaw(A2S_SERVERQUERY_GETCHALLENGE, nil, S2C_CHALLENGE)
+	if err != nil && !errors.Is(err, ErrChallengeRequired) {
+		return err
+	}
+	return nil
 }
 
 
@@ -189,26 +501,167 @@ func (c *Client) sendRequest(packetType byte, payload []byte, expectResponse byt
 // It returns an error if the response is not what was expected.
 // It does not retry if the response is a challenge.
 func (c *Client) sendRequestRaw(packetType byte, payload []byte, expectResponse byte) ([]byte, error) {
-	packet := c.buildPacket(packetType, payload)
-	
-	c.conn.SetDeadline(time.Now().Add(c.timeout))
-	
-	if _, err := c.conn.Write(packet); err != nil {
-		return nil, fmt.Errorf("write error: %w", err)
+	return c.exchange(c.buildPacket(packetType, payload), expectResponse)
+}
+
+// exchange sends a complete packet and waits for the response, recording the
+// round-trip time.
+func (c *Client) exchange(packet []byte, expectResponse byte) (response []byte, err error) {
+	received := 0
+	if c.tracer != nil {
+		scope := c.trace(SpanExchange,
+			Attribute{AttrRequestType, packetTypeAttribute(packet[4])},
+			Attribute{AttrRequestSize, len(packet)})
+		defer func() {
+			challenge := errors.Is(err, ErrChallengeRequired)
+			scope.span.SetAttributes(Attribute{AttrResponseSize, received}, Attribute{AttrChallenge, challenge})
+			if challenge {
+				scope.end(nil)
+			} else {
+				scope.end(err)
+			}
+		}()
 	}
 
-	buffer := make([]byte, 4096)
-	n, err := c.conn.Read(buffer)
+	if err := c.waitRateLimit(); err != nil {
+		return nil, err
+	}
+	timeout, err := c.exchangeTimeout(packet)
 	if err != nil {
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			return nil, ErrTimeout
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	c.conn.SetDeadline(deadline)
+	if c.ctx != nil {
+		stop := context.AfterFunc(c.ctx, c.interrupt)
+		defer stop()
+	}
+
+	start := c.clock.Now()
+	buffer := c.readBuffer()
+	var n int
+	if len(c.candidates) > 0 {
+		n, err = c.raceCandidates(packet, buffer, deadline)
+	} else {
+		c.logPacket(logSent, packet)
+		if _, err := c.conn.Write(packet); err != nil {
+			return nil, c.connError("write", err)
 		}
-		return nil, fmt.Errorf("read error: %w", err)
+		n, err = c.conn.Read(buffer)
 	}
+	if err != nil {
+		err = c.connError("read", err)
+		if errors.Is(err, ErrTimeout) {
+			c.reportToBreaker(false)
+			c.resolveStale = true
+		}
+		return nil, err
+	}
+	c.reportToBreaker(true)
+	c.lastRTT = c.clock.Now().Sub(start)
+	c.logPacket(logReceived, buffer[:n])
+	c.lastSize = n
+	received = n
+	c.updateSRTT(c.lastRTT)
 
 	return c.processResponse(buffer[:n], expectResponse)
 }
 
+// interrupt cuts blocked reads short when the context of the query in flight
+// is canceled. It runs on a goroutine of its own.
+func (c *Client) interrupt() {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	now := time.Now()
+	c.conn.SetReadDeadline(now)
+	for _, conn := range c.racing {
+		conn.SetReadDeadline(now)
+	}
+}
+
+// reportToBreaker tells the circuit breaker whether the server answered.
+func (c *Client) reportToBreaker(answered bool) {
+	if c.breaker == nil {
+		return
+	}
+	addr, ok := c.remoteAddrPort()
+	if !ok {
+		return
+	}
+	if answered {
+		c.breaker.success(addr)
+	} else {
+		c.breaker.failure(addr, c.clock.Now())
+	}
+}
+
+// remoteAddrPort returns the address of the connected server.
+func (c *Client) remoteAddrPort() (netip.AddrPort, bool) {
+	if c.conn == nil {
+		return netip.AddrPort{}, false
+	}
+	addr, err := netip.ParseAddrPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return netip.AddrPort{}, false
+	}
+	return netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port()), true
+}
+
+// connError translates an error of the connection: ErrClientClosed if Close
+// caused it, ErrTimeout if the deadline passed.
+func (c *Client) connError(op string, err error) error {
+	if c.closed.Load() {
+		return ErrClientClosed
+	}
+	if c.ctx != nil && c.ctx.Err() == context.Canceled {
+		return context.Canceled
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return ErrTimeout
+	}
+	return fmt.Errorf("%s error: %w", op, err)
+}
+
+// LastResponseSize returns the size in bytes of the last datagram received
+// from the server, including the packet header.
+func (c *Client) LastResponseSize() int {
+	return c.lastSize
+}
+
+// SRTT returns the smoothed round-trip time to the server, an exponentially
+// weighted moving average of every exchange so far (as in TCP, RFC 6298).
+// It is zero until the first reply has been received.
+func (c *Client) SRTT() time.Duration {
+	return c.srtt
+}
+
+func (c *Client) updateSRTT(rtt time.Duration) {
+	if c.srtt == 0 {
+		c.srtt = rtt
+		return
+	}
+	c.srtt += (rtt - c.srtt) / 8
+}
+
+// requestTimeout returns the timeout for the next exchange. With adaptive
+// timeouts enabled it is derived from the smoothed RTT, bounded by the
+// configured minimum and the fixed client timeout.
+func (c *Client) requestTimeout() time.Duration {
+	if c.adaptiveFactor <= 0 || c.srtt == 0 {
+		return c.timeout
+	}
+
+	timeout := time.Duration(float64(c.srtt) * c.adaptiveFactor)
+	if timeout < c.adaptiveMinimum {
+		timeout = c.adaptiveMinimum
+	}
+	if timeout > c.timeout {
+		timeout = c.timeout
+	}
+	return timeout
+}
+
 // buildPacket builds a packet for sending to the server.
 // The packet consists of:
 // * a 4-byte header of 0xFFFFFFFF
@@ -217,12 +670,13 @@ func (c *Client) sendRequestRaw(packetType byte, payload []byte, expectResponse
 // * the challenge number, if required
 // The challenge number is required for A2S_PLAYER and A2S_RULES packets,
 // and is sent at the beginning of the packet. For A2S_INFO packets, the
-// challenge number is sent at the end of the packet if it is not -1.
+// challenge number is sent at the end of the packet if it is not -1, unless
+// WithLegacyNoChallenge is set.
 // The packet is built in a single allocation, and the returned []byte
 // is suitable for sending directly over the wire.
 func (c *Client) buildPacket(packetType byte, payload []byte) []byte {
 	challengeAtBeginning := packetType == A2S_PLAYER || packetType == A2S_RULES
-	challengeAtEnd := packetType == A2S_INFO && c.challenge != -1
+	challengeAtEnd := packetType == A2S_INFO && c.challenge != -1 && !c.noInfoChallenge
 
 	size := 4 + 1
 	if challengeAtBeginning {
@@ -298,6 +752,7 @@ func (c *Client) processSinglePacket(data []byte, expect byte) ([]byte, error) {
 			return nil, ErrShortResponse
 		}
 		c.challenge = int32(binary.LittleEndian.Uint32(data[1:5]))
+		c.storeChallenge()
 		return nil, ErrChallengeRequired
 	}
 
@@ -309,237 +764,65 @@ func (c *Client) processSinglePacket(data []byte, expect byte) ([]byte, error) {
 }
 
 
+// processSplitPacket reassembles a split response, reading the remaining
+// fragments from the connection, and processes the joined payload like a
+// single packet. Compressed responses are decompressed and their checksum is
+// verified. Details of the split are available from LastSplit.
 func (c *Client) processSplitPacket(data []byte, expect byte) ([]byte, error) {
-	if len(data) < 9 {
-		return nil, ErrShortResponse
-	}
-	
-	payloadStart := 4 + 1 + 1
-	if len(data) > 8 {
-		payloadStart += 2
-	}
-	
-	if payloadStart >= len(data) {
-		return nil, ErrInvalidResponse
-	}
-	
-	return c.processSinglePacket(data[payloadStart:], expect)
-}
-// parseSourceInfo parses the response to A2S_INFO request from Source (HL2) servers and returns a ServerInfo object.
-// It returns an error if the response is too short.
-
-func (c *Client) parseSourceInfo(data []byte) (*ServerInfo, error) {
-	if len(data) < 20 {
-		return nil, ErrShortResponse
-	}
-
-	info := &ServerInfo{}
-	offset := 0
-
-	info.Protocol = data[offset]
-	offset++
-
-	info.Name = readString(data, &offset)
-	info.Map = readString(data, &offset)
-	info.Folder = readString(data, &offset)
-	info.Game = readString(data, &offset)
-
-	if offset+2 <= len(data) {
-		info.AppID = binary.LittleEndian.Uint16(data[offset:])
-		offset += 2
-	}
-
-	if offset+3 > len(data) {
-		return nil, ErrShortResponse
-	}
-	info.Players = data[offset]
-	offset++
-	info.MaxPlayers = data[offset]
-	offset++
-	info.Bots = data[offset]
-	offset++
-
-	if offset+4 > len(data) {
-		return nil, ErrShortResponse
-	}
-	info.ServerType = data[offset]
-	offset++
-	info.Environment = data[offset]
-	offset++
-	info.Visibility = data[offset]
-	offset++
-	info.VAC = data[offset]
-	offset++
-
-	info.Version = readString(data, &offset)
-
-	if offset < len(data) {
-		info.EDF = data[offset]
-		offset++
-
-		if info.EDF&0x80 != 0 && offset+2 <= len(data) {
-			info.GamePort = binary.LittleEndian.Uint16(data[offset:])
-			offset += 2
-		}
-
-		if info.EDF&0x10 != 0 && offset+8 <= len(data) {
-			info.SteamID = binary.LittleEndian.Uint64(data[offset:])
-			offset += 8
+	var collector splitCollector
+
+	assembler, err := collector.add(data)
+	buffer := c.readBuffer()
+	for err == nil && assembler == nil {
+		n, readErr := c.conn.Read(buffer)
+		if readErr != nil {
+			return nil, c.connError("read", readErr)
 		}
-
-		if info.EDF&0x40 != 0 && offset+2 <= len(data) {
-			info.SourceTV.Port = binary.LittleEndian.Uint16(data[offset:])
-			offset += 2
-			info.SourceTV.Name = readString(data, &offset)
+		c.logPacket(logReceived, buffer[:n])
+		if n < 4 || binary.LittleEndian.Uint32(buffer) != uint32(SPLIT_FLAG) {
+			continue
 		}
-
-		if info.EDF&0x20 != 0 {
-			tags := readString(data, &offset)
-			if tags != "" {
-				
-			}
-		}
-
-		if info.EDF&0x01 != 0 && offset+8 <= len(data) {
-			info.GameID = binary.LittleEndian.Uint64(data[offset:])
-		}
-	}
-
-	return info, nil
-}
-
-// parseGoldSourceInfo parses the response to A2S_INFO request from GoldSource (HL1) servers and returns a ServerInfo object.
-// It returns an error if the response is too short.
-func (c *Client) parseGoldSourceInfo(data []byte) (*ServerInfo, error) {
-	info := &ServerInfo{}
-	offset := 0
-
-	_ = readString(data, &offset)
-	info.Name = readString(data, &offset)
-	info.Map = readString(data, &offset)
-	info.Folder = readString(data, &offset)
-	info.Game = readString(data, &offset)
-
-	if offset+2 > len(data) {
-		return nil, ErrShortResponse
-	}
-	info.Players = data[offset]
-	offset++
-	info.MaxPlayers = data[offset]
-	offset++
-
-	info.Protocol = data[offset]
-	offset++
-	info.ServerType = data[offset]
-	offset++
-	info.Environment = data[offset]
-	offset++
-	info.Visibility = data[offset]
-	offset++
-
-	modFlag := data[offset]
-	offset++
-
-	if modFlag == 1 {
-		_ = readString(data, &offset)
-		_ = readString(data, &offset)
-		offset++
-		offset += 4
-		offset += 4
-		offset++
-		offset++
-	}
-
-	info.VAC = data[offset]
-	offset++
-
-	if offset < len(data) {
-		info.Bots = data[offset]
+		assembler, err = collector.add(buffer[4:n])
 	}
-
-	return info, nil
-}
-
-
-// parsePlayersResponse parses the response to A2S_PLAYER request and returns a slice of PlayerInfo.
-// The function returns an error if the response is too short.
-// The players are returned in the order they were received from the server.
-func (c *Client) parsePlayersResponse(data []byte) ([]PlayerInfo, error) {
-	if len(data) < 1 {
-		return nil, ErrShortResponse
+	if err != nil {
+		return nil, err
 	}
 
-	offset := 0
-	numPlayers := int(data[offset])
-	offset++
-
-	players := make([]PlayerInfo, 0, numPlayers)
-	
-	for i := 0; i < numPlayers && offset < len(data); i++ {
-		var player PlayerInfo
-		
-		player.Index = data[offset]
-		offset++
-		
-		player.Name = readString(data, &offset)
-		
-		if offset+4 > len(data) {
-			return nil, ErrShortResponse
-		}
-		player.Score = int32(binary.LittleEndian.Uint32(data[offset:]))
-		offset += 4
-		
-		if offset+4 > len(data) {
-			return nil, ErrShortResponse
+	payload := assembler.payload()
+	if assembler.info.Compressed {
+		if payload, err = assembler.decompress(); err != nil {
+			return nil, err
 		}
-		player.Duration = math.Float32frombits(binary.LittleEndian.Uint32(data[offset:]))
-		offset += 4
-
-		players = append(players, player)
+		c.checksum = max(c.checksum, assembler.info.Checksum)
 	}
 
-	return players, nil
-}
-
-// parseRulesResponse parses the response to A2S_RULES request and returns a slice of server rules.
-// Each rule is a key-value pair, where key and value are strings.
-// The function returns an error if the response is too short.
-// The rules are returned in the order they were received from the server.
-func (c *Client) parseRulesResponse(data []byte) ([]Rule, error) {
-	if len(data) < 2 {
-		return nil, ErrShortResponse
+	info := assembler.info
+	c.lastSplit = &info
+	if info.Checksum == ChecksumMismatch && !c.skipChecksum {
+		return nil, ErrChecksumMismatch
 	}
 
-	offset := 0
-	numRules := int(binary.LittleEndian.Uint16(data[offset:]))
-	offset += 2
-
-	rules := make([]Rule, 0, numRules)
-	
-	for i := 0; i < numRules && offset < len(data); i++ {
-		var rule Rule
-		rule.Name = readString(data, &offset)
-		rule.Value = readString(data, &offset)
-		rules = append(rules, rule)
+	if len(payload) < 4 || binary.LittleEndian.Uint32(payload) != uint32(Header) {
+		return nil, ErrInvalidResponse
 	}
+	return c.processSinglePacket(payload[4:], expect)
+}
 
-	return rules, nil
+// LastSplit returns details of the last response the server split into
+// several packets, or nil if it has not sent one yet.
+func (c *Client) LastSplit() *SplitInfo {
+	return c.lastSplit
 }
-// readString reads a null-terminated string from the given byte slice, starting from the given offset. It returns the string and updates the offset to point after the null byte. If the offset points to the end of the slice, it returns an empty string.
 
-func readString(data []byte, offset *int) string {
-	if *offset >= len(data) {
-		return ""
+// readBuffer returns the buffer to read the next response into. The buffer of
+// an attached ParseState is recycled, except with zero-copy strings, where the
+// previous results still point into it.
+func (c *Client) readBuffer() []byte {
+	if c.decoder.State == nil || c.decoder.ZeroCopyStrings {
+		return make([]byte, readBufferSize)
 	}
-	
-	start := *offset
-	for *offset < len(data) && data[*offset] != 0 {
-		*offset++
+	if len(c.decoder.State.buffer) < readBufferSize {
+		c.decoder.State.buffer = make([]byte, readBufferSize)
 	}
-	
-	str := string(data[start:*offset])
-	if *offset < len(data) {
-		*offset++
-	}
-	return str
-}
\ No newline at end of file
+	return c.decoder.State.buffer
+}
@@ -0,0 +1,208 @@
+// Package a2stest provides an in-process game server for testing code that
+// queries servers, without sockets, ports or network access. Every client
+// gets its own in-memory connection, so tests using it can run in parallel.
+//
+//	server := &a2stest.Server{
+//		Info:    &a2s.ServerInfo{Name: "Test Server", Map: "de_dust2", Folder: "cstrike", AppID: 240},
+//		Players: []a2s.PlayerInfo{{Name: "alice", Score: 12}},
+//	}
+//	client, err := server.NewClient(time.Second)
+//	...
+//	info, err := client.GetInfo()
+//
+// This package is experimental: its API may change between minor releases.
+package a2stest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+// Addr is the address clients of NewClient are connected to.
+var Addr = netip.MustParseAddrPort("192.0.2.1:27015")
+
+// clientAddr is the local address of the connections of Dial.
+var clientAddr = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 100), Port: 27005}
+
+// DefaultChallenge is handed out if Server.Challenge is zero.
+const DefaultChallenge int32 = 0x1D2C3B4A
+
+const (
+	// maxPacketSize is the size above which responses are split, like
+	// Source servers do.
+	maxPacketSize = 1400
+	// fragmentSize is the payload size of the fragments of a split
+	// response.
+	fragmentSize = 1248
+)
+
+var infoPayload = []byte("Source Engine Query\x00")
+
+
+// Server answers queries from fixed data. Nil Info, Players or Rules make it
+// ignore the respective query, so it times out like on a server that
+// disabled it. The fields must not be changed while clients are connected.
+type Server struct {
+	Info    *a2s.ServerInfo
+	Players []a2s.PlayerInfo
+	Rules   a2s.Rules
+
+	// Challenge is required with players and rules requests. Requests
+	// without it are answered with the challenge, like real servers do.
+	Challenge int32
+	// InfoChallenge requires the challenge with info requests too, as
+	// Source servers do since 2020.
+	InfoChallenge bool
+
+	// Latency delays every response.
+	Latency time.Duration
+	// Drop reports whether a request is dropped instead of answered, to
+	// simulate packet loss. It may be called concurrently.
+	Drop func(request []byte) bool
+}
+
+// NewClient returns a client with the given timeout, connected to the server
+// at Addr. The options configure the client as in a2s.NewClient.
+func (s *Server) NewClient(timeout time.Duration, opts ...a2s.Option) (*a2s.Client, error) {
+	client := a2s.NewClient(timeout, append(opts, a2s.WithDialer(s.Dial))...)
+	if err := client.ConnectAddrPort(Addr); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Dial returns an in-memory connection to the server, which is served until
+// the connection is closed. It is an a2s.DialFunc for a2s.WithDialer; the
+// address only shows as the remote address of the connection. Like UDP, the
+// connection never blocks a writer: responses that arrive after the client
+// gave up are queued for its next read, and dropped once the queue is full.
+func (s *Server) Dial(ctx context.Context, addr netip.AddrPort) (net.Conn, error) {
+	client, server := newDatagramPipe(clientAddr, net.UDPAddrFromAddrPort(addr))
+	go s.serve(server)
+	return client, nil
+}
+
+// serve answers the requests arriving on conn until it is closed.
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	buffer := make([]byte, 65536)
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return
+		}
+		request := buffer[:n]
+		if s.Drop != nil && s.Drop(request) {
+			continue
+		}
+		response := s.respond(request)
+		if response == nil {
+			continue
+		}
+		if s.Latency > 0 {
+			time.Sleep(s.Latency)
+		}
+		for _, packet := range split(response) {
+			if _, err := conn.Write(packet); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// respond returns the response to a request, or nil if there is none.
+func (s *Server) respond(request []byte) []byte {
+	if len(request) < 5 || binary.LittleEndian.Uint32(request) != a2s.Header {
+		return nil
+	}
+
+	var response []byte
+	switch payload := request[5:]; request[4] {
+	case a2s.A2S_INFO:
+		if s.Info == nil || !bytes.HasPrefix(payload, infoPayload) {
+			return nil
+		}
+		if s.InfoChallenge && !s.challenged(payload[len(infoPayload):]) {
+			return s.challengePacket()
+		}
+		response, _ = a2s.EncodeInfo(s.Info)
+
+	case a2s.A2S_PLAYER:
+		if s.Players == nil {
+			return nil
+		}
+		if !s.challenged(payload) {
+			return s.challengePacket()
+		}
+		var appID uint16
+		if s.Info != nil {
+			appID = s.Info.AppID
+		}
+		response, _ = a2s.EncodePlayers(s.Players, appID)
+
+	case a2s.A2S_RULES:
+		if s.Rules == nil {
+			return nil
+		}
+		if !s.challenged(payload) {
+			return s.challengePacket()
+		}
+		response, _ = a2s.EncodeRules(s.Rules)
+
+	case a2s.A2S_SERVERQUERY_GETCHALLENGE:
+		return s.challengePacket()
+
+	case a2s.A2S_PING:
+		response = binary.LittleEndian.AppendUint32(nil, a2s.Header)
+		response = append(response, a2s.S2A_PING)
+		response = append(response, "00000000000000\x00"...)
+	}
+	return response
+}
+
+// challenge returns the challenge of the server.
+func (s *Server) challenge() int32 {
+	if s.Challenge == 0 {
+		return DefaultChallenge
+	}
+	return s.Challenge
+}
+
+// challenged reports whether the payload starts with the challenge.
+func (s *Server) challenged(payload []byte) bool {
+	return len(payload) >= 4 && int32(binary.LittleEndian.Uint32(payload)) == s.challenge()
+}
+
+func (s *Server) challengePacket() []byte {
+	packet := binary.LittleEndian.AppendUint32(nil, a2s.Header)
+	packet = append(packet, a2s.S2C_CHALLENGE)
+	return binary.LittleEndian.AppendUint32(packet, uint32(s.challenge()))
+}
+
+// split splits a response larger than maxPacketSize into fragments with the
+// Source split header.
+func split(response []byte) [][]byte {
+	if len(response) <= maxPacketSize {
+		return [][]byte{response}
+	}
+
+	total := (len(response) + fragmentSize - 1) / fragmentSize
+	packets := make([][]byte, 0, total)
+	for i := 0; i < total; i++ {
+		packet := binary.LittleEndian.AppendUint32(nil, a2s.SPLIT_FLAG)
+		packet = binary.LittleEndian.AppendUint32(packet, 1)
+		packet = append(packet, byte(total), byte(i))
+		packet = binary.LittleEndian.AppendUint16(packet, fragmentSize)
+		packet = append(packet, response[i*fragmentSize:min((i+1)*fragmentSize, len(response))]...)
+		packets = append(packets, packet)
+	}
+	return packets
+}
@@ -0,0 +1,54 @@
+package a2stest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+	"github.com/notedevil/valve-a2s/a2stest"
+)
+
+
+func TestServerMinimalInfo(t *testing.T) {
+	server := &a2stest.Server{Info: &a2s.ServerInfo{}}
+	client, err := server.NewClient(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	info, err := client.GetInfo()
+	if err != nil {
+		t.Fatalf("GetInfo of an empty info: %v", err)
+	}
+	if info.Name != "" || info.Map != "" {
+		t.Errorf("GetInfo = %+v, want an empty info", info)
+	}
+}
+
+// TestServerLateResponse lets the first query time out before its response
+// arrives. The late response must not block the server, so the next query
+// on the same client still gets an answer.
+func TestServerLateResponse(t *testing.T) {
+	server := &a2stest.Server{
+		Info:    &a2s.ServerInfo{Name: "late", Map: "de_dust2", Folder: "cstrike"},
+		Latency: 80 * time.Millisecond,
+	}
+	client, err := server.NewClient(50 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := client.GetInfo(); !errors.Is(err, a2s.ErrTimeout) {
+		t.Fatalf("first GetInfo: got %v, want a timeout", err)
+	}
+	info, err := client.GetInfo()
+	if err != nil {
+		t.Fatalf("GetInfo after a late response: %v", err)
+	}
+	if info.Name != "late" {
+		t.Errorf("got info of %q", info.Name)
+	}
+}
@@ -0,0 +1,163 @@
+package a2stest
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+
+// queueSize is the number of datagrams an end of a connection holds before
+// further ones are dropped, like a full socket buffer drops them.
+const queueSize = 16
+
+// datagramConn is one end of an in-memory datagram connection. Writes never
+// block: each one queues a datagram at the other end, or drops it if nobody
+// reads there. Reads return one datagram each and honor the read deadline.
+// Closing either end closes both.
+type datagramConn struct {
+	in     chan []byte
+	out    chan []byte
+	closed chan struct{}
+	close  *sync.Once
+
+	local  net.Addr
+	remote net.Addr
+
+	readDeadline deadline
+}
+
+// newDatagramPipe returns the two ends of an in-memory datagram connection.
+func newDatagramPipe(clientAddr, serverAddr net.Addr) (client, server *datagramConn) {
+	toClient := make(chan []byte, queueSize)
+	toServer := make(chan []byte, queueSize)
+	closed := make(chan struct{})
+	once := new(sync.Once)
+
+	client = &datagramConn{in: toClient, out: toServer, closed: closed, close: once, local: clientAddr, remote: serverAddr}
+	server = &datagramConn{in: toServer, out: toClient, closed: closed, close: once, local: serverAddr, remote: clientAddr}
+	client.readDeadline.init()
+	server.readDeadline.init()
+	return client, server
+}
+
+func (c *datagramConn) Read(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	case <-c.readDeadline.wait():
+		return 0, os.ErrDeadlineExceeded
+	default:
+	}
+
+	select {
+	case datagram := <-c.in:
+		return copy(b, datagram), nil
+	case <-c.closed:
+		return 0, net.ErrClosed
+	case <-c.readDeadline.wait():
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+func (c *datagramConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	select {
+	case c.out <- append([]byte(nil), b...):
+	default:
+	}
+	return len(b), nil
+}
+
+func (c *datagramConn) Close() error {
+	c.close.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *datagramConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *datagramConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *datagramConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *datagramConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline does nothing, since writes never block.
+func (c *datagramConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+
+// deadline is a read deadline whose channel is closed when it passes, so
+// readers waiting on it wake up even if it is changed while they wait.
+type deadline struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	expired chan struct{}
+}
+
+func (d *deadline) init() {
+	d.expired = make(chan struct{})
+}
+
+// set moves the deadline to t, or removes it if t is zero.
+func (d *deadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		// The timer fired; wait for it to close the channel.
+		<-d.expired
+	}
+	d.timer = nil
+
+	expired := isClosed(d.expired)
+	if t.IsZero() {
+		if expired {
+			d.expired = make(chan struct{})
+		}
+		return
+	}
+	if wait := time.Until(t); wait > 0 {
+		if expired {
+			d.expired = make(chan struct{})
+		}
+		ch := d.expired
+		d.timer = time.AfterFunc(wait, func() { close(ch) })
+		return
+	}
+	if !expired {
+		close(d.expired)
+	}
+}
+
+// wait returns a channel that is closed when the deadline passes.
+func (d *deadline) wait() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.expired
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,23 @@
+//go:build linux && !tinygo
+
+package a2s
+
+import "syscall"
+
+
+// bindToDevice returns a dialer control function that binds the socket to
+// the named interface with SO_BINDTODEVICE, so its packets leave through the
+// interface even if the routing table would send them elsewhere, like a VPN
+// interface without a default route.
+func bindToDevice(name string) func(network, address string, raw syscall.RawConn) error {
+	return func(network, address string, raw syscall.RawConn) error {
+		var err error
+		controlErr := raw.Control(func(fd uintptr) {
+			err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, name)
+		})
+		if controlErr != nil {
+			return controlErr
+		}
+		return err
+	}
+}
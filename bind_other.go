@@ -0,0 +1,13 @@
+//go:build !js && (!linux || tinygo)
+
+package a2s
+
+import "syscall"
+
+
+// bindToDevice returns nil: binding to the address of the interface is all
+// that can be done portably, which routes the packets through the interface
+// on systems with a source-based or strong host model.
+func bindToDevice(name string) func(network, address string, raw syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,170 @@
+//go:build !js
+
+package a2s
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+)
+
+
+// CircuitBreaker stops clients from querying servers that keep timing out,
+// so pollers do not spend every cycle waiting on dead servers. It counts the
+// consecutive unanswered requests per server address. Once they reach the
+// threshold, the circuit of the server opens: queries fail right away with
+// ErrCircuitOpen until the reopen interval has passed. A single query is then
+// let through as a probe while the others keep failing; if the probe goes
+// unanswered too, the circuit opens again for twice as long, up to a maximum.
+// Any reply closes the circuit and resets the interval. See
+// WithCircuitBreaker.
+//
+// Share one CircuitBreaker between all clients that query the same servers.
+// It is safe for concurrent use. The breaker keeps time with the clocks of
+// the clients that use it, so they should share a Clock as well.
+type CircuitBreaker struct {
+	threshold int
+	interval  time.Duration
+	maximum   time.Duration
+
+	mu       sync.Mutex
+	circuits map[netip.AddrPort]*circuit
+	// swept is the number of circuits after the last sweep of stale ones.
+	swept int
+}
+
+type circuit struct {
+	failures int
+	// opened counts how often the circuit opened in a row, which doubles
+	// the reopen interval each time.
+	opened int
+	reopen time.Time
+	// probe is when the probe of a half-open circuit was let through, or
+	// zero if none is in flight.
+	probe time.Time
+	// failed is the time of the last unanswered request.
+	failed time.Time
+}
+
+// NewCircuitBreaker returns a breaker that opens the circuit of a server
+// after threshold consecutive unanswered requests, for interval at first and
+// at most maximum.
+func NewCircuitBreaker(threshold int, interval, maximum time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: max(threshold, 1),
+		interval:  interval,
+		maximum:   max(maximum, interval),
+		circuits:  make(map[netip.AddrPort]*circuit),
+	}
+}
+
+// CircuitBreakerStats summarizes the circuits of a CircuitBreaker.
+type CircuitBreakerStats struct {
+	// Failing is the number of servers with unanswered requests.
+	Failing int `json:"failing"`
+	// Open is the number of servers whose queries are currently rejected.
+	Open int `json:"open"`
+}
+
+// Stats returns a summary of the circuits at the current system time.
+func (b *CircuitBreaker) Stats() CircuitBreakerStats {
+	return b.StatsAt(time.Now())
+}
+
+// StatsAt is like Stats, but counts the circuits that are open at now, for
+// clients with a Clock other than SystemClock.
+func (b *CircuitBreaker) StatsAt(now time.Time) CircuitBreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := CircuitBreakerStats{Failing: len(b.circuits)}
+	for _, c := range b.circuits {
+		if now.Before(c.reopen) {
+			stats.Open++
+		}
+	}
+	return stats
+}
+
+// allow reports whether a query to addr may be sent at now. Once the reopen
+// time of an open circuit has passed, only one caller at a time gets through
+// as the probe. A probe that is never reported, because its query failed
+// for another reason, is replaced after the initial interval.
+func (b *CircuitBreaker) allow(addr netip.AddrPort, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[addr]
+	if c == nil || c.failures < b.threshold {
+		return true
+	}
+	if now.Before(c.reopen) {
+		return false
+	}
+	if !c.probe.IsZero() && now.Sub(c.probe) < b.interval {
+		return false
+	}
+	c.probe = now
+	return true
+}
+
+// success closes the circuit of addr.
+func (b *CircuitBreaker) success(addr netip.AddrPort) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.circuits, addr)
+}
+
+// failure counts an unanswered request to addr at now and opens its circuit
+// once the threshold is reached.
+func (b *CircuitBreaker) failure(addr netip.AddrPort, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[addr]
+	if c == nil {
+		c = &circuit{failed: now}
+		b.circuits[addr] = c
+		b.sweep(now)
+	}
+	c.failures++
+	c.failed = now
+	if c.failures < b.threshold {
+		return
+	}
+
+	c.reopen = now.Add(b.reopenInterval(c.opened))
+	c.opened++
+	c.probe = time.Time{}
+}
+
+// reopenInterval returns how long a circuit that opened the given number of
+// times in a row stays open: the interval, doubled each time up to the
+// maximum. It stops doubling at the maximum, so it cannot overflow.
+func (b *CircuitBreaker) reopenInterval(opened int) time.Duration {
+	interval := b.interval
+	for range opened {
+		if interval > b.maximum/2 {
+			return b.maximum
+		}
+		interval *= 2
+	}
+	return min(interval, b.maximum)
+}
+
+// sweep drops the circuits without an unanswered request for longer than
+// the maximum interval whenever the map has doubled in size since the last
+// sweep, so servers that are never queried again are forgotten, whether or
+// not their circuit ever opened.
+func (b *CircuitBreaker) sweep(now time.Time) {
+	if len(b.circuits) <= max(2*b.swept, 1024) {
+		return
+	}
+	for addr, c := range b.circuits {
+		if now.Sub(c.failed) > b.maximum {
+			delete(b.circuits, addr)
+		}
+	}
+	b.swept = len(b.circuits)
+}
@@ -0,0 +1,72 @@
+//go:build !js
+
+package a2s
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+
+var breakerAddr = netip.MustParseAddrPort("203.0.113.10:27015")
+
+// TestCircuitBreakerBackoffOverflow opens a circuit far more often than it
+// takes the doubled interval to overflow a Duration.
+func TestCircuitBreakerBackoffOverflow(t *testing.T) {
+	b := NewCircuitBreaker(1, 5*time.Second, time.Hour)
+	now := time.Unix(1700000000, 0)
+	for range 100 {
+		b.failure(breakerAddr, now)
+	}
+	if got := b.circuits[breakerAddr].reopen.Sub(now); got != time.Hour {
+		t.Errorf("circuit reopens after %v, want the maximum of 1h", got)
+	}
+	if b.allow(breakerAddr, now.Add(time.Minute)) {
+		t.Error("open circuit let a query through")
+	}
+}
+
+// TestCircuitBreakerSweepIdle checks that circuits of servers that failed
+// without ever reaching the threshold are forgotten as well.
+func TestCircuitBreakerSweepIdle(t *testing.T) {
+	b := NewCircuitBreaker(3, time.Second, time.Minute)
+	now := time.Unix(1700000000, 0)
+	for i := range 1024 {
+		b.failure(netip.AddrPortFrom(netip.AddrFrom4([4]byte{198, 51, byte(i >> 8), byte(i)}), 27015), now)
+	}
+
+	b.failure(breakerAddr, now.Add(2*time.Minute))
+	if len(b.circuits) != 1 {
+		t.Errorf("%d circuits after the sweep, want 1", len(b.circuits))
+	}
+}
+
+// TestCircuitBreakerHalfOpen checks that only one query probes a circuit
+// whose reopen time has passed.
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Second, time.Minute)
+	now := time.Unix(1700000000, 0)
+	b.failure(breakerAddr, now)
+
+	now = now.Add(time.Second)
+	if !b.allow(breakerAddr, now) {
+		t.Fatal("probe rejected after the reopen interval")
+	}
+	if b.allow(breakerAddr, now) {
+		t.Error("second query let through while the probe is in flight")
+	}
+
+	b.failure(breakerAddr, now)
+	if b.allow(breakerAddr, now.Add(time.Second)) {
+		t.Error("query let through after the probe failed")
+	}
+	now = now.Add(2 * time.Second)
+	if !b.allow(breakerAddr, now) {
+		t.Fatal("probe rejected after the doubled interval")
+	}
+	b.success(breakerAddr)
+	if !b.allow(breakerAddr, now) || !b.allow(breakerAddr, now) {
+		t.Error("closed circuit rejected queries")
+	}
+}
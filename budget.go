@@ -0,0 +1,112 @@
+//go:build !js
+
+package a2s
+
+import (
+	"fmt"
+	"time"
+)
+
+
+// DeadlineBudget bounds the total time of an operation, such as QueryAll or
+// ConnectContext, and splits it across the phases of the operation. See
+// WithDeadlineBudget.
+//
+// Resolve, Challenge and Data are relative shares: a single phase may use at
+// most Total times its share divided by the sum of all shares. Resolving the
+// server address is the resolve phase, exchanges that fetch a challenge are
+// the challenge phase and all other exchanges the data phase. A phase with a
+// zero share, like every phase if all shares are zero, is not capped on its
+// own and may use whatever is left of Total.
+type DeadlineBudget struct {
+	Total     time.Duration
+	Resolve   float64
+	Challenge float64
+	Data      float64
+}
+
+type budgetPhase byte
+
+const (
+	phaseResolve budgetPhase = iota
+	phaseChallenge
+	phaseData
+)
+
+// limit returns the most time a single step of the phase may take, or 0 if
+// there is no budget or no cap for the phase.
+func (b DeadlineBudget) limit(phase budgetPhase) time.Duration {
+	if b.Total <= 0 {
+		return 0
+	}
+
+	sum := b.Resolve + b.Challenge + b.Data
+	if sum <= 0 {
+		return b.Total
+	}
+	share := [...]float64{b.Resolve, b.Challenge, b.Data}[phase]
+	return time.Duration(float64(b.Total) * share / sum)
+}
+
+
+// startBudget starts the deadline budget of a public operation, unless it is
+// part of an enclosing one, like GetInfo within QueryAll. The returned
+// function must be called when the operation ends.
+func (c *Client) startBudget() func() {
+	if c.budget.Total <= 0 {
+		return func() {}
+	}
+
+	if c.operations == 0 {
+		c.budgetDeadline = c.clock.Now().Add(c.budget.Total)
+	}
+	c.operations++
+	return func() {
+		c.operations--
+	}
+}
+
+// resolveTimeout returns the most time resolving the server address may
+// take: its share of the budget, cut short by what is left of Total, or all
+// that is left of Total if the resolve phase has no share. It returns false
+// if no budget applies.
+func (c *Client) resolveTimeout() (time.Duration, bool) {
+	if c.operations == 0 {
+		return 0, false
+	}
+
+	timeout := c.budgetDeadline.Sub(c.clock.Now())
+	if limit := c.budget.limit(phaseResolve); limit > 0 {
+		timeout = min(timeout, limit)
+	}
+	return timeout, true
+}
+
+// exchangeTimeout returns the timeout for sending the packet: the request
+// timeout, cut short by the context and the deadline budget of the current
+// operation. It returns ErrTimeout if either is exhausted.
+func (c *Client) exchangeTimeout(packet []byte) (time.Duration, error) {
+	timeout, err := c.contextTimeout(c.requestTimeout())
+	if err != nil || c.operations == 0 {
+		return timeout, err
+	}
+
+	remaining := c.budgetDeadline.Sub(c.clock.Now())
+	if remaining <= 0 {
+		return 0, fmt.Errorf("%w: deadline budget exhausted", ErrTimeout)
+	}
+
+	phase := phaseData
+	switch packet[4] {
+	case A2S_SERVERQUERY_GETCHALLENGE:
+		phase = phaseChallenge
+	case A2S_PLAYER, A2S_RULES:
+		if c.challenge == -1 {
+			phase = phaseChallenge
+		}
+	}
+	if limit := c.budget.limit(phase); limit > 0 {
+		timeout = min(timeout, limit)
+	}
+	return min(timeout, remaining), nil
+}
@@ -0,0 +1,37 @@
+//go:build !js
+
+package a2s
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+
+// TestBudgetBoundsResolve resolves with a DNS server that never answers and
+// a budget without a resolve share, which must still end the resolve once
+// the total is spent.
+func TestBudgetBoundsResolve(t *testing.T) {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	client := NewClient(time.Minute,
+		WithResolver(resolver),
+		WithDeadlineBudget(DeadlineBudget{Total: 50 * time.Millisecond, Challenge: 1, Data: 1}),
+	)
+	defer client.Close()
+
+	start := time.Now()
+	if err := client.Connect("game.example.invalid:27015"); err == nil {
+		t.Fatal("Connect succeeded without an answer from DNS")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("resolving took %v with a total budget of 50ms", elapsed)
+	}
+}
@@ -0,0 +1,271 @@
+// Package cache memoizes server queries per address, for web frontends and
+// other services that would otherwise query the same popular servers for
+// every request they handle.
+//
+// This package is experimental: its API may change between minor releases.
+package cache
+
+import (
+	"sync"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+// Default lifetimes of cached results.
+const (
+	DefaultInfoTTL    = 10 * time.Second
+	DefaultPlayersTTL = 10 * time.Second
+	DefaultRulesTTL   = time.Minute
+)
+
+
+// Client queries servers like a2s.Client, but answers from a cache while the
+// last result for the address is fresh. It is safe for concurrent use.
+//
+// Concurrent calls that miss the cache for the same address and query share a
+// single query to the server and its result, error included. With all TTLs
+// set to zero, a Client only deduplicates concurrent queries.
+//
+// Results are shared between callers and must not be modified. Failed queries
+// are not cached.
+type Client struct {
+	timeout    time.Duration
+	clientOpts []a2s.Option
+	stale      time.Duration
+	clock      a2s.Clock
+
+	mu      sync.Mutex
+	info    table[*a2s.ServerInfo]
+	players table[[]a2s.PlayerInfo]
+	rules   table[a2s.Rules]
+	stats   Stats
+}
+
+// Stats are counters of how lookups were answered, see Client.Stats.
+type Stats struct {
+	// Hits counts lookups answered with a fresh result.
+	Hits uint64 `json:"hits"`
+	// StaleHits counts lookups answered with an expired result while it was
+	// refreshed, see WithStaleWhileRevalidate.
+	StaleHits uint64 `json:"stale_hits"`
+	// Misses counts lookups that queried the server.
+	Misses uint64 `json:"misses"`
+	// Shared counts lookups that waited for the query of a concurrent miss.
+	Shared uint64 `json:"shared"`
+	// Entries is the number of cached results.
+	Entries int `json:"entries"`
+}
+
+// Stats returns the lookup counters since the client was created. The hit
+// rate is (Hits+StaleHits+Shared) divided by the sum of all counters.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.info.entries) + len(c.players.entries) + len(c.rules.entries)
+	return stats
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithTTL sets how long results of each query stay fresh. The defaults are
+// DefaultInfoTTL, DefaultPlayersTTL and DefaultRulesTTL.
+func WithTTL(info, players, rules time.Duration) Option {
+	return func(c *Client) {
+		c.info.ttl = info
+		c.players.ttl = players
+		c.rules.ttl = rules
+	}
+}
+
+// WithStaleWhileRevalidate keeps answering with an expired result for up to
+// the given duration after it expired, while it is refreshed in the
+// background. Only the first caller after expiry triggers the refresh, so a
+// popular server is queried at most once per TTL, and no caller waits for it.
+// Callers that miss the cache while the refresh runs, because the stale
+// period has run out too, wait for the refresh instead of querying again.
+func WithStaleWhileRevalidate(stale time.Duration) Option {
+	return func(c *Client) {
+		c.stale = stale
+	}
+}
+
+// WithClock makes the cache, and the clients it queries with, keep time with
+// clock instead of a2s.SystemClock, so tests can expire entries without
+// waiting.
+func WithClock(clock a2s.Clock) Option {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
+
+// WithClientOptions configures the a2s.Client used for every query.
+func WithClientOptions(opts ...a2s.Option) Option {
+	return func(c *Client) {
+		c.clientOpts = append(c.clientOpts, opts...)
+	}
+}
+
+// New returns a caching client whose queries use the given timeout.
+func New(timeout time.Duration, opts ...Option) *Client {
+	c := &Client{
+		timeout: timeout,
+		info:    table[*a2s.ServerInfo]{ttl: DefaultInfoTTL},
+		players: table[[]a2s.PlayerInfo]{ttl: DefaultPlayersTTL},
+		rules:   table[a2s.Rules]{ttl: DefaultRulesTTL},
+		clock:   a2s.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.clientOpts = append([]a2s.Option{a2s.WithClock(c.clock)}, c.clientOpts...)
+	return c
+}
+
+
+// GetInfo returns the server info of the server at addr.
+func (c *Client) GetInfo(addr string) (*a2s.ServerInfo, error) {
+	return lookup(c, &c.info, addr, (*a2s.Client).GetInfo)
+}
+
+// GetPlayers returns the players on the server at addr.
+func (c *Client) GetPlayers(addr string) ([]a2s.PlayerInfo, error) {
+	return lookup(c, &c.players, addr, (*a2s.Client).GetPlayers)
+}
+
+// GetRules returns the rules of the server at addr.
+func (c *Client) GetRules(addr string) (a2s.Rules, error) {
+	return lookup(c, &c.rules, addr, (*a2s.Client).GetRules)
+}
+
+// Invalidate drops every cached result for addr.
+func (c *Client) Invalidate(addr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.info.entries, addr)
+	delete(c.players.entries, addr)
+	delete(c.rules.entries, addr)
+}
+
+
+// table caches the results of one query type by address.
+type table[T any] struct {
+	ttl     time.Duration
+	entries map[string]*entry[T]
+	// inflight holds the queries in progress, shared by concurrent misses.
+	inflight map[string]*call[T]
+	// swept is the number of entries after the last sweep of expired ones.
+	swept int
+}
+
+type entry[T any] struct {
+	value   T
+	fetched time.Time
+}
+
+// call is a query in progress.
+type call[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// lookup answers from the table if possible and queries the server otherwise.
+func lookup[T any](c *Client, t *table[T], addr string, query func(*a2s.Client) (T, error)) (T, error) {
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	e := t.entries[addr]
+	if e != nil {
+		age := now.Sub(e.fetched)
+		if age < t.ttl {
+			c.stats.Hits++
+			c.mu.Unlock()
+			return e.value, nil
+		}
+		if age < t.ttl+c.stale {
+			if t.inflight[addr] == nil {
+				go run(c, t, addr, t.begin(addr), query)
+			}
+			c.stats.StaleHits++
+			c.mu.Unlock()
+			return e.value, nil
+		}
+	}
+
+	if running := t.inflight[addr]; running != nil {
+		c.stats.Shared++
+		c.mu.Unlock()
+		<-running.done
+		return running.value, running.err
+	}
+	c.stats.Misses++
+	running := t.begin(addr)
+	c.mu.Unlock()
+
+	run(c, t, addr, running, query)
+	return running.value, running.err
+}
+
+// begin registers a query of addr in progress, which concurrent misses and
+// refreshes then share. The caller must hold c.mu.
+func (t *table[T]) begin(addr string) *call[T] {
+	if t.inflight == nil {
+		t.inflight = make(map[string]*call[T])
+	}
+	running := &call[T]{done: make(chan struct{})}
+	t.inflight[addr] = running
+	return running
+}
+
+// run queries the server for the call begun by lookup, in the foreground for
+// a miss and in the background for a refresh, and caches its result. A failed
+// refresh keeps the stale result, which is served until it runs out; the
+// next lookup after that queries the server again.
+func run[T any](c *Client, t *table[T], addr string, running *call[T], query func(*a2s.Client) (T, error)) {
+	running.value, running.err = fetch(c, addr, query)
+
+	c.mu.Lock()
+	delete(t.inflight, addr)
+	if running.err == nil {
+		t.store(addr, running.value, c.clock.Now(), c.stale)
+	}
+	c.mu.Unlock()
+	close(running.done)
+}
+
+// store caches a result fetched at now. Expired entries are swept whenever the table has
+// doubled in size since the last sweep, so it does not grow without bounds
+// when many different addresses are queried.
+func (t *table[T]) store(addr string, value T, now time.Time, stale time.Duration) {
+	if t.entries == nil {
+		t.entries = make(map[string]*entry[T])
+	}
+	t.entries[addr] = &entry[T]{value: value, fetched: now}
+
+	if len(t.entries) > max(2*t.swept, 64) {
+		for key, e := range t.entries {
+			if now.Sub(e.fetched) >= t.ttl+stale {
+				delete(t.entries, key)
+			}
+		}
+		t.swept = len(t.entries)
+	}
+}
+
+// fetch connects to the server and runs the query.
+func fetch[T any](c *Client, addr string, query func(*a2s.Client) (T, error)) (T, error) {
+	client := a2s.NewClient(c.timeout, c.clientOpts...)
+	if err := client.Connect(addr); err != nil {
+		var zero T
+		return zero, err
+	}
+	defer client.Close()
+
+	return query(client)
+}
@@ -0,0 +1,173 @@
+package cache_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+	"github.com/notedevil/valve-a2s/a2stest"
+	"github.com/notedevil/valve-a2s/cache"
+)
+
+
+// fakeClock is an a2s.Clock that only advances when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.advance(d)
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// countingServer returns an in-memory server and a counter of the info
+// queries it received.
+func countingServer(latency time.Duration) (*a2stest.Server, *atomic.Int32) {
+	queries := new(atomic.Int32)
+	server := &a2stest.Server{
+		Info:    &a2s.ServerInfo{Name: "cached", Map: "de_dust2", Folder: "cstrike"},
+		Latency: latency,
+		Drop: func(request []byte) bool {
+			if len(request) > 4 && request[4] == a2s.A2S_INFO {
+				queries.Add(1)
+			}
+			return false
+		},
+	}
+	return server, queries
+}
+
+func newCache(server *a2stest.Server, clock *fakeClock, opts ...cache.Option) *cache.Client {
+	opts = append(opts, cache.WithClock(clock), cache.WithClientOptions(a2s.WithDialer(server.Dial)))
+	return cache.New(time.Second, opts...)
+}
+
+var addr = a2stest.Addr.String()
+
+
+func TestCacheTTL(t *testing.T) {
+	server, queries := countingServer(0)
+	clock := newFakeClock()
+	c := newCache(server, clock, cache.WithTTL(10*time.Second, 0, 0))
+
+	for range 3 {
+		if _, err := c.GetInfo(addr); err != nil {
+			t.Fatal(err)
+		}
+		clock.advance(time.Second)
+	}
+	if got := queries.Load(); got != 1 {
+		t.Errorf("%d queries while the result was fresh, want 1", got)
+	}
+
+	clock.advance(10 * time.Second)
+	if _, err := c.GetInfo(addr); err != nil {
+		t.Fatal(err)
+	}
+	if got := queries.Load(); got != 2 {
+		t.Errorf("%d queries after the result expired, want 2", got)
+	}
+	if stats := c.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("got %+v, want 2 hits and 2 misses", stats)
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	server, queries := countingServer(0)
+	clock := newFakeClock()
+	c := newCache(server, clock, cache.WithTTL(10*time.Second, 0, 0), cache.WithStaleWhileRevalidate(10*time.Second))
+
+	if _, err := c.GetInfo(addr); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(15 * time.Second)
+	if _, err := c.GetInfo(addr); err != nil {
+		t.Fatal(err)
+	}
+	if stats := c.Stats(); stats.StaleHits != 1 {
+		t.Errorf("got %+v, want a stale hit", stats)
+	}
+
+	// The refresh runs in the background; once it stored its result, the
+	// next lookup is a fresh hit.
+	deadline := time.Now().Add(time.Second)
+	for c.Stats().Hits == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed result never served")
+		}
+		if _, err := c.GetInfo(addr); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := queries.Load(); got != 2 {
+		t.Errorf("%d queries, want 2", got)
+	}
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	server, queries := countingServer(50 * time.Millisecond)
+	c := newCache(server, newFakeClock())
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			if _, err := c.GetInfo(addr); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if got := queries.Load(); got != 1 {
+		t.Errorf("%d queries for concurrent misses, want 1", got)
+	}
+	if stats := c.Stats(); stats.Misses+stats.Shared != 10 || stats.Misses != 1 {
+		t.Errorf("got %+v, want 1 miss shared by the others", stats)
+	}
+}
+
+// TestCacheMissJoinsRefresh misses the cache while a background refresh of
+// the same result runs, which must wait for the refresh instead of querying
+// the server again.
+func TestCacheMissJoinsRefresh(t *testing.T) {
+	server, queries := countingServer(100 * time.Millisecond)
+	clock := newFakeClock()
+	c := newCache(server, clock, cache.WithTTL(10*time.Second, 0, 0), cache.WithStaleWhileRevalidate(10*time.Second))
+
+	if _, err := c.GetInfo(addr); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(15 * time.Second)
+	if _, err := c.GetInfo(addr); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(10 * time.Second)
+	if _, err := c.GetInfo(addr); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := queries.Load(); got != 2 {
+		t.Errorf("%d queries, want the refresh to be shared", got)
+	}
+	if stats := c.Stats(); stats.Shared != 1 {
+		t.Errorf("got %+v, want the miss to share the refresh", stats)
+	}
+}
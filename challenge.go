@@ -0,0 +1,122 @@
+//go:build !js
+
+package a2s
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+)
+
+
+// ChallengeCache remembers the last challenge number of each server, so
+// short-lived clients, like one per poll, reuse it instead of paying a
+// challenge round trip for every GetPlayers and GetRules. Servers rotate
+// their challenges; a client that sends a stale one gets a new challenge in
+// reply and retries with it, which also updates the cache. See
+// WithChallengeCache.
+//
+// A ChallengeCache is safe for concurrent use.
+type ChallengeCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[netip.AddrPort]cachedChallenge
+	// swept is the number of entries after the last sweep of expired ones.
+	swept int
+}
+
+type cachedChallenge struct {
+	challenge int32
+	stored    time.Time
+}
+
+// NewChallengeCache returns a cache that reuses challenges for up to ttl.
+func NewChallengeCache(ttl time.Duration) *ChallengeCache {
+	return &ChallengeCache{
+		ttl:     ttl,
+		entries: make(map[netip.AddrPort]cachedChallenge),
+	}
+}
+
+// Invalidate forgets the challenge of addr, e.g. after the server
+// restarted.
+func (cc *ChallengeCache) Invalidate(addr netip.AddrPort) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	delete(cc.entries, addr)
+}
+
+// load returns the challenge of addr if it is younger than the TTL at now.
+func (cc *ChallengeCache) load(addr netip.AddrPort, now time.Time) (int32, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	e, ok := cc.entries[addr]
+	if !ok || now.Sub(e.stored) >= cc.ttl {
+		return 0, false
+	}
+	return e.challenge, true
+}
+
+// store remembers the challenge of addr, received at now. Expired entries
+// are swept whenever the map has doubled in size since the last sweep.
+func (cc *ChallengeCache) store(addr netip.AddrPort, challenge int32, now time.Time) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.entries[addr] = cachedChallenge{challenge: challenge, stored: now}
+	if len(cc.entries) <= max(2*cc.swept, 1024) {
+		return
+	}
+	for key, e := range cc.entries {
+		if now.Sub(e.stored) >= cc.ttl {
+			delete(cc.entries, key)
+		}
+	}
+	cc.swept = len(cc.entries)
+}
+
+
+// loadChallenge takes the challenge of the server from the challenge cache,
+// if the client does not know one yet.
+func (c *Client) loadChallenge() {
+	if c.challenges == nil || c.challenge != -1 {
+		return
+	}
+	addr, ok := c.remoteAddrPort()
+	if !ok {
+		return
+	}
+	if challenge, ok := c.challenges.load(addr, c.clock.Now()); ok {
+		c.challenge = challenge
+		c.cachedChallenge = true
+	}
+}
+
+// storeChallenge puts a challenge handed out by the server into the
+// challenge cache.
+func (c *Client) storeChallenge() {
+	c.cachedChallenge = false
+	if c.challenges == nil {
+		return
+	}
+	if addr, ok := c.remoteAddrPort(); ok {
+		c.challenges.store(addr, c.challenge, c.clock.Now())
+	}
+}
+
+// dropCachedChallenge forgets a challenge taken from the cache that the
+// server did not answer, since some servers silently drop requests with an
+// invalid challenge instead of handing out a new one.
+func (c *Client) dropCachedChallenge() {
+	if !c.cachedChallenge {
+		return
+	}
+	if addr, ok := c.remoteAddrPort(); ok {
+		c.challenges.Invalidate(addr)
+	}
+	c.challenge = -1
+	c.cachedChallenge = false
+}
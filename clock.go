@@ -0,0 +1,23 @@
+//go:build !js
+
+package a2s
+
+import "time"
+
+
+// Clock is the source of time of a Client: round-trip times, deadline
+// budgets, retry backoff and rate limit waits are measured and waited with
+// it. Tests can substitute a fake clock that advances instantly, see
+// WithClock. Socket deadlines always use the real time, as the network does.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the Clock of package time, used unless WithClock is given.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
@@ -0,0 +1,147 @@
+//go:build !a2s_minimal
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+// benchResult summarizes the queries of one type sent by bench.
+type benchResult struct {
+	Query    string        `json:"query"`
+	Sent     int           `json:"sent"`
+	Received int           `json:"received"`
+	Loss     float64       `json:"loss"`
+	Errors   int           `json:"errors"`
+	Min      time.Duration `json:"min"`
+	P50      time.Duration `json:"p50"`
+	P95      time.Duration `json:"p95"`
+	P99      time.Duration `json:"p99"`
+	Max      time.Duration `json:"max"`
+	Size     int           `json:"size"`
+}
+
+type benchQuery struct {
+	name string
+	run  func(*a2s.Client) error
+}
+
+var benchQueries = []benchQuery{
+	{"info", func(c *a2s.Client) error { _, err := c.GetInfo(); return err }},
+	{"players", func(c *a2s.Client) error { _, err := c.GetPlayers(); return err }},
+	{"rules", func(c *a2s.Client) error { _, err := c.GetRules(); return err }},
+}
+
+
+// runBench sends every query type n times, from c concurrent clients, and
+// reports latency percentiles, loss and response sizes per type. Timeouts
+// count as loss, any other failure as an error. Queries are never retried, so
+// --retries is ignored and loss is not hidden.
+func runBench(args []string) error {
+	var flags queryFlags
+	fs := newFlagSet("bench", "<host:port>")
+	flags.register(fs)
+	n := fs.Int("n", 100, "number of queries per type")
+	concurrency := fs.Int("c", 1, "number of concurrent clients")
+	addr, err := parseAddr(fs, args)
+	if err != nil {
+		return err
+	}
+
+	clients := make([]*a2s.Client, max(*concurrency, 1))
+	for i := range clients {
+		if clients[i], err = flags.connect(addr); err != nil {
+			return err
+		}
+		defer clients[i].Close()
+	}
+
+	var results []benchResult
+	for _, query := range benchQueries {
+		results = append(results, bench(clients, query, *n))
+	}
+
+	if flags.json {
+		return printJSON(results)
+	}
+
+	w := newTable()
+	fmt.Fprintln(w, "QUERY\tSENT\tOK\tLOSS\tERRORS\tMIN\tP50\tP95\tP99\tMAX\tSIZE")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\t%d\t%s\t%s\t%s\t%s\t%s\t%dB\n",
+			r.Query, r.Sent, r.Received, r.Loss, r.Errors,
+			r.Min.Round(time.Microsecond), r.P50.Round(time.Microsecond), r.P95.Round(time.Microsecond),
+			r.P99.Round(time.Microsecond), r.Max.Round(time.Microsecond), r.Size)
+	}
+	return w.Flush()
+}
+
+// bench runs the query n times, spread over the clients.
+func bench(clients []*a2s.Client, query benchQuery, n int) benchResult {
+	result := benchResult{Query: query.name, Sent: n}
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		size      int
+		wg        sync.WaitGroup
+	)
+	jobs := make(chan struct{})
+	for _, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				start := time.Now()
+				err := query.run(client)
+				elapsed := time.Since(start)
+
+				mu.Lock()
+				switch {
+				case err == nil:
+					latencies = append(latencies, elapsed)
+					size = max(size, client.LastResponseSize())
+				case errors.Is(err, a2s.ErrTimeout):
+				default:
+					result.Errors++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	wg.Wait()
+
+	result.Received = len(latencies)
+	if n > 0 {
+		result.Loss = float64(n-result.Received-result.Errors) / float64(n) * 100
+	}
+	result.Size = size
+	if len(latencies) == 0 {
+		return result
+	}
+
+	slices.Sort(latencies)
+	result.Min = latencies[0]
+	result.P50 = percentile(latencies, 50)
+	result.P95 = percentile(latencies, 95)
+	result.P99 = percentile(latencies, 99)
+	result.Max = latencies[len(latencies)-1]
+	return result
+}
+
+// percentile returns the p-th percentile of sorted samples, using the nearest
+// rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	return sorted[(len(sorted)*p+99)/100-1]
+}
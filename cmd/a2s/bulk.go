@@ -0,0 +1,184 @@
+//go:build !a2s_minimal
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+// bulkResult is one line of bulk output. The snapshot fields are inlined and
+// left out if the server could not be queried at all. Metadata from the input
+// is kept either way.
+type bulkResult struct {
+	Address string `json:"address"`
+	*a2s.ServerSnapshot
+	Metadata map[string]any `json:"metadata,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+
+// runBulk queries a list of servers, one "host:port" per line, concurrently
+// and streams one JSON object per server as soon as it has been queried, so
+// the output can be piped into jq or an ingestion pipeline. The address may
+// be followed by key=value pairs, which are copied into the metadata of the
+// result. Empty lines and lines starting with # are ignored.
+func runBulk(args []string) error {
+	var flags queryFlags
+	fs := newFlagSet("bulk", "")
+	flags.register(fs)
+	input := fs.String("input", "-", "file with one server address per line, - for stdin")
+	output := fs.String("output", "ndjson", "output format: ndjson, or json for a single array")
+	all := fs.Bool("all", false, "query players and rules as well as the info")
+	concurrency := fs.Int("concurrency", 64, "number of servers queried at once")
+	sockets := fs.Int("sockets", 4, "number of UDP sockets shared by all queries")
+	rate := fs.Float64("rate", 0, "maximum requests per second sent in total, 0 for no limit")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		fs.Usage()
+		return errUsage
+	}
+	if flags.json {
+		*output = "json"
+	}
+	if *output != "ndjson" && *output != "json" {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+
+	var r io.Reader = os.Stdin
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	mux, err := a2s.NewMultiplexer(*sockets, flags.timeout, muxOptions(*rate)...)
+	if err != nil {
+		return err
+	}
+	defer mux.Close()
+
+	addrs := make(chan string)
+	var readErr error
+	go func() {
+		defer close(addrs)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			addrs <- line
+		}
+		readErr = scanner.Err()
+	}()
+
+	var (
+		mu      sync.Mutex
+		enc     = json.NewEncoder(os.Stdout)
+		results = []bulkResult{}
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < max(*concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range addrs {
+				result := queryBulk(mux, &flags, line, *all)
+
+				mu.Lock()
+				if *output == "ndjson" {
+					enc.Encode(result)
+				} else {
+					results = append(results, result)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if readErr != nil {
+		return readErr
+	}
+	if *output == "json" {
+		return printJSON(results)
+	}
+	return nil
+}
+
+// queryBulk queries a single server of the list. With all set, failures of
+// the players or rules queries are reported next to the partial snapshot.
+func queryBulk(mux *a2s.Multiplexer, flags *queryFlags, line string, all bool) bulkResult {
+	addr, metadata := parseBulkLine(line)
+	result := bulkResult{Address: flags.redaction.Address(addr), Metadata: metadata}
+
+	client, err := mux.Client(addr, flags.options(a2s.WithMetadata(metadata))...)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer client.Close()
+
+	// The target is invalid for hostnames, whose servers are then keyed by
+	// their SteamID, or by address in changelog if they have none.
+	target, _ := netip.ParseAddrPort(addr)
+	err = flags.retry(func() (err error) {
+		if all {
+			result.ServerSnapshot, err = client.QueryAll()
+			return err
+		}
+
+		start := time.Now()
+		info, err := client.GetInfo()
+		if err != nil {
+			return err
+		}
+		result.ServerSnapshot = &a2s.ServerSnapshot{
+			Info:      info,
+			RTT:       time.Since(start),
+			Timestamp: time.Now(),
+			Key:       a2s.NewServerKey(info, target),
+		}
+		return nil
+	})
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.ServerSnapshot = flags.redaction.Snapshot(result.ServerSnapshot)
+	return result
+}
+
+// parseBulkLine splits a line of the server list into the address and the
+// key=value pairs following it. Words without "=" are ignored.
+func parseBulkLine(line string) (string, map[string]any) {
+	fields := strings.Fields(line)
+	var metadata map[string]any
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]any)
+		}
+		metadata[key] = value
+	}
+	return fields[0], metadata
+}
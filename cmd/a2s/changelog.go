@@ -0,0 +1,214 @@
+//go:build !a2s_minimal
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+// serverChangelog is the rule changes of one server, oldest first.
+type serverChangelog struct {
+	Key     string           `json:"key"`
+	Address string           `json:"address"`
+	Name    string           `json:"name,omitempty"`
+	Changes []changelogEntry `json:"changes"`
+}
+
+// changelogEntry is a rule change that happened between the last snapshot
+// with the old value, Since, and the first one with the new value, Time.
+type changelogEntry struct {
+	Since time.Time `json:"since"`
+	Time  time.Time `json:"time"`
+	a2s.RuleChange
+}
+
+
+// runChangelog reads the output of "a2s bulk --all" collected over time,
+// e.g. by a cron job appending to a file, and prints the rule changes of
+// every server in chronological order as Markdown, or as JSON with --json.
+// Servers are told apart by their key, so the history of servers with a
+// persistent SteamID survives moves. Snapshots without rules, because the
+// query failed or bulk ran without --all, are skipped rather than reported
+// as every rule being removed.
+func runChangelog(args []string) error {
+	fs := newFlagSet("changelog", "[file...]")
+	asJSON := fs.Bool("json", false, "print JSON instead of Markdown")
+	only := fs.String("rules", "", "comma-separated rules (cvars) to report, all if empty")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		positional = []string{"-"}
+	}
+
+	var snapshots []bulkResult
+	for _, name := range positional {
+		read, err := readBulkResults(name)
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, read...)
+	}
+
+	var filter []string
+	if *only != "" {
+		filter = strings.Split(strings.ToLower(*only), ",")
+	}
+	changelogs := buildChangelogs(snapshots, filter)
+	if *asJSON {
+		return printJSON(changelogs)
+	}
+	return printChangelogs(os.Stdout, changelogs)
+}
+
+// readBulkResults reads a file of bulk output, - for stdin, in either of its
+// formats: JSON lines or a single array.
+func readBulkResults(name string) ([]bulkResult, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var results []bulkResult
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return results, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		if strings.HasPrefix(string(raw), "[") {
+			var batch []bulkResult
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			results = append(results, batch...)
+			continue
+		}
+		var result bulkResult
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		results = append(results, result)
+	}
+}
+
+// buildChangelogs groups the snapshots by server, orders them by time and
+// diffs the rules of consecutive ones. Only the rules named in filter, in
+// lower case, are reported if it is not empty. Servers without changes are
+// left out.
+func buildChangelogs(snapshots []bulkResult, filter []string) []serverChangelog {
+	byServer := make(map[string][]bulkResult)
+	for _, s := range snapshots {
+		if s.ServerSnapshot == nil || s.Rules == nil {
+			continue
+		}
+		key := string(s.Key)
+		if key == "" {
+			key = s.Address
+		}
+		byServer[key] = append(byServer[key], s)
+	}
+
+	changelogs := []serverChangelog{}
+	for key, history := range byServer {
+		slices.SortStableFunc(history, func(a, b bulkResult) int {
+			return a.Timestamp.Compare(b.Timestamp)
+		})
+
+		latest := history[len(history)-1]
+		changelog := serverChangelog{Key: key, Address: latest.Address}
+		if latest.Info != nil {
+			changelog.Name = latest.Info.Name
+		}
+		for i := 1; i < len(history); i++ {
+			for _, change := range a2s.DiffRules(history[i-1].Rules, history[i].Rules) {
+				if len(filter) > 0 && !slices.Contains(filter, strings.ToLower(change.Name)) {
+					continue
+				}
+				changelog.Changes = append(changelog.Changes, changelogEntry{
+					Since:      history[i-1].Timestamp,
+					Time:       history[i].Timestamp,
+					RuleChange: change,
+				})
+			}
+		}
+		if len(changelog.Changes) > 0 {
+			changelogs = append(changelogs, changelog)
+		}
+	}
+
+	slices.SortFunc(changelogs, func(a, b serverChangelog) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return changelogs
+}
+
+// printChangelogs writes the changelogs as a Markdown document with a
+// section per server.
+func printChangelogs(w io.Writer, changelogs []serverChangelog) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Rule changes")
+	if len(changelogs) == 0 {
+		fmt.Fprintln(bw)
+		fmt.Fprintln(bw, "No rule changes found.")
+	}
+	for _, changelog := range changelogs {
+		title := changelog.Address
+		if changelog.Name != "" {
+			title = fmt.Sprintf("%s (%s)", changelog.Name, changelog.Address)
+		}
+		fmt.Fprintf(bw, "\n## %s\n\n", title)
+
+		for _, e := range changelog.Changes {
+			fmt.Fprintf(bw, "- %s to %s: ", formatChangeTime(e.Since), formatChangeTime(e.Time))
+			switch {
+			case e.Added:
+				fmt.Fprintf(bw, "added %s = %s\n", markdownCode(e.Name), markdownCode(e.New))
+			case e.Removed:
+				fmt.Fprintf(bw, "removed %s, was %s\n", markdownCode(e.Name), markdownCode(e.Old))
+			default:
+				fmt.Fprintf(bw, "%s changed from %s to %s\n", markdownCode(e.Name), markdownCode(e.Old), markdownCode(e.New))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func formatChangeTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05 UTC")
+}
+
+// markdownCode formats s as inline code. Values containing backticks are
+// fenced with two, and empty values are shown as "".
+func markdownCode(s string) string {
+	switch {
+	case s == "":
+		return `""`
+	case strings.Contains(s, "`"):
+		return "`` " + s + " ``"
+	default:
+		return "`" + s + "`"
+	}
+}
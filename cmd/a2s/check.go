@@ -0,0 +1,193 @@
+//go:build !a2s_minimal
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+// Exit statuses of monitoring plugins, as understood by Nagios and Icinga.
+const (
+	statusOK exitStatus = iota
+	statusWarning
+	statusCritical
+	statusUnknown
+)
+
+var statusNames = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// exitStatus is returned by commands that need a specific exit status.
+type exitStatus int
+
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
+
+// checkFlags are the flags of the monitoring plugin mode of info.
+type checkFlags struct {
+	enabled     bool
+	warnTime    time.Duration
+	critTime    time.Duration
+	warnPlayers string
+	critPlayers string
+}
+
+func (f *checkFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.enabled, "check", false, "run as a Nagios/Icinga check plugin")
+	fs.DurationVar(&f.warnTime, "warn-time", 0, "with --check, warn if the response takes longer")
+	fs.DurationVar(&f.critTime, "crit-time", 0, "with --check, critical if the response takes longer")
+	fs.StringVar(&f.warnPlayers, "warn-players", "", "with --check, warn if the player count is outside this Nagios `range`")
+	fs.StringVar(&f.critPlayers, "crit-players", "", "with --check, critical if the player count is outside this Nagios `range`")
+}
+
+
+// runCheck queries the server info and prints a single line of plugin output
+// with performance data, e.g.
+//
+//	A2S OK - Server: 12/24 players on de_dust2, 0.021s response time | time=0.021000s;0.5;1;0 players=12;;;0;24
+//
+// The returned exitStatus tells the monitoring system the result: OK, WARNING
+// or CRITICAL depending on the thresholds, CRITICAL if the server does not
+// answer and UNKNOWN if the arguments are invalid.
+func runCheck(flags *queryFlags, check *checkFlags, addr string) error {
+	warnPlayers, err := parseNagiosRange(check.warnPlayers)
+	if err != nil {
+		return checkResult(statusUnknown, fmt.Sprintf("--warn-players: %v", err), "")
+	}
+	critPlayers, err := parseNagiosRange(check.critPlayers)
+	if err != nil {
+		return checkResult(statusUnknown, fmt.Sprintf("--crit-players: %v", err), "")
+	}
+
+	client, err := flags.connect(addr)
+	if err != nil {
+		return checkResult(statusUnknown, err.Error(), "")
+	}
+	defer client.Close()
+
+	var (
+		info    *a2s.ServerInfo
+		elapsed time.Duration
+	)
+	err = flags.retry(func() (err error) {
+		start := time.Now()
+		info, err = client.GetInfo()
+		elapsed = time.Since(start)
+		return err
+	})
+	if err != nil {
+		return checkResult(statusCritical, fmt.Sprintf("no response from %s: %v", addr, err), "")
+	}
+
+	status := statusOK
+	raise := func(s exitStatus) {
+		status = max(status, s)
+	}
+	switch {
+	case check.critTime > 0 && elapsed > check.critTime:
+		raise(statusCritical)
+	case check.warnTime > 0 && elapsed > check.warnTime:
+		raise(statusWarning)
+	}
+	players := float64(info.Players)
+	switch {
+	case critPlayers != nil && critPlayers.alert(players):
+		raise(statusCritical)
+	case warnPlayers != nil && warnPlayers.alert(players):
+		raise(statusWarning)
+	}
+
+	message := fmt.Sprintf("%s: %d/%d players on %s, %.3fs response time",
+		info.Name, info.Players, info.MaxPlayers, info.Map, elapsed.Seconds())
+	perfdata := fmt.Sprintf("time=%fs;%s;%s;0 players=%d;%s;%s;0;%d",
+		elapsed.Seconds(), thresholdSeconds(check.warnTime), thresholdSeconds(check.critTime),
+		info.Players, check.warnPlayers, check.critPlayers, info.MaxPlayers)
+	return checkResult(status, message, perfdata)
+}
+
+// checkResult prints the plugin output line and returns the status, or nil
+// for OK.
+func checkResult(status exitStatus, message, perfdata string) error {
+	line := fmt.Sprintf("A2S %s - %s", statusNames[status], message)
+	if perfdata != "" {
+		line += " | " + perfdata
+	}
+	fmt.Println(line)
+
+	if status == statusOK {
+		return nil
+	}
+	return status
+}
+
+func thresholdSeconds(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+
+// nagiosRange is a threshold range in the format of the monitoring plugins
+// guidelines: "10" alerts outside 0..10, "10:" below 10, "~:10" above 10,
+// "10:20" outside 10..20 and "@10:20" inside 10..20.
+type nagiosRange struct {
+	start  float64
+	end    float64
+	inside bool
+}
+
+// parseNagiosRange parses a range, or returns nil for an empty string.
+func parseNagiosRange(s string) (*nagiosRange, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	r := &nagiosRange{end: math.Inf(1)}
+	spec := s
+	if strings.HasPrefix(spec, "@") {
+		r.inside = true
+		spec = spec[1:]
+	}
+
+	start, end, hasColon := strings.Cut(spec, ":")
+	if !hasColon {
+		start, end = "0", spec
+	}
+
+	var err error
+	switch start {
+	case "~":
+		r.start = math.Inf(-1)
+	case "":
+		r.start = 0
+	default:
+		if r.start, err = strconv.ParseFloat(start, 64); err != nil {
+			return nil, fmt.Errorf("invalid range %q", s)
+		}
+	}
+	if end != "" {
+		if r.end, err = strconv.ParseFloat(end, 64); err != nil {
+			return nil, fmt.Errorf("invalid range %q", s)
+		}
+	}
+	if r.start > r.end {
+		return nil, fmt.Errorf("invalid range %q, start is greater than end", s)
+	}
+	return r, nil
+}
+
+// alert reports whether v should raise an alert.
+func (r *nagiosRange) alert(v float64) bool {
+	within := v >= r.start && v <= r.end
+	return within == r.inside
+}
@@ -0,0 +1,104 @@
+//go:build !a2s_minimal
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+
+// commandFlags returns the names of the flags of cmd, in lexical order, for
+// the completion scripts.
+func commandFlags(cmd *command) []string {
+	fs := commandFlagSet(cmd)
+	if fs == nil {
+		return nil
+	}
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	return names
+}
+
+
+// runCompletion prints a completion script for the given shell. To enable it,
+// e.g. for bash:
+//
+//	source <(a2s completion bash)
+func runCompletion(args []string) error {
+	fs := newFlagSet("completion", "bash|zsh|fish")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	switch positional[0] {
+	case "bash":
+		fmt.Fprint(os.Stdout, bashCompletion())
+	case "zsh":
+		fmt.Fprint(os.Stdout, "autoload -U +X bashcompinit && bashcompinit\n", bashCompletion())
+	case "fish":
+		fmt.Fprint(os.Stdout, fishCompletion())
+	default:
+		return fmt.Errorf("unsupported shell %q", positional[0])
+	}
+	return nil
+}
+
+
+func bashCompletion() string {
+	var b strings.Builder
+
+	names := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+
+	b.WriteString("_a2s() {\n")
+	b.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("\tif [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	b.WriteString("\t\treturn\n")
+	b.WriteString("\tfi\n")
+	b.WriteString("\tcase \"${COMP_WORDS[1]}\" in\n")
+	for i := range commands {
+		cmd := &commands[i]
+		flags := commandFlags(cmd)
+		switch {
+		case cmd.name == "completion":
+			fmt.Fprintf(&b, "\tcompletion) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", "bash zsh fish")
+		case len(flags) > 0:
+			fmt.Fprintf(&b, "\t%s) [[ \"$cur\" == -* ]] && COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", cmd.name, "--"+strings.Join(flags, " --"))
+		}
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -o default -F _a2s a2s\n")
+
+	return b.String()
+}
+
+func fishCompletion() string {
+	var b strings.Builder
+
+	b.WriteString("complete -c a2s -f\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "complete -c a2s -n __fish_use_subcommand -a %s -d %q\n", cmd.name, cmd.summary)
+	}
+	for i := range commands {
+		for _, flag := range commandFlags(&commands[i]) {
+			fmt.Fprintf(&b, "complete -c a2s -n '__fish_seen_subcommand_from %s' -l %s\n", commands[i].name, flag)
+		}
+	}
+	b.WriteString("complete -c a2s -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'\n")
+
+	return b.String()
+}
@@ -0,0 +1,97 @@
+//go:build !a2s_minimal
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+	"github.com/notedevil/valve-a2s/exporter"
+)
+
+
+// runExporter serves a Prometheus exporter whose /probe endpoint queries the
+// server given in the target parameter, like the blackbox exporter.
+func runExporter(args []string) error {
+	fs := newFlagSet("exporter", "")
+	listen := fs.String("listen", ":9137", "address to listen on")
+	timeout := fs.Duration("timeout", 3*time.Second, "timeout of each request")
+	rules := fs.String("rules", "", "comma-separated rules (cvars) to export as a2s_rule gauges")
+	breaker := fs.Int("breaker", 0, "skip servers for a while after this many unanswered requests in a row, 0 to always probe")
+	dropLabels := fs.String("drop-labels", "", "comma-separated a2s_info labels to leave out")
+	redact := fs.String("redact", "", "hide `details` in a2s_info: comma-separated steamids and passwords")
+	var limits, hashed stringList
+	fs.Var(&limits, "label-limit", "cap the distinct values of an a2s_info label, as `label=n` (repeatable)")
+	fs.Var(&hashed, "hash-label", "hash an a2s_info label into n buckets, as `label=n` (repeatable)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	redaction, err := parseRedaction(*redact)
+	if err != nil {
+		return fmt.Errorf("--redact: %w", err)
+	}
+
+	var opts []exporter.Option
+	if redaction != nil {
+		opts = append(opts, exporter.WithRedaction(redaction))
+	}
+	if *rules != "" {
+		opts = append(opts, exporter.WithRules(strings.Split(*rules, ",")...))
+	}
+	if *breaker > 0 {
+		opts = append(opts, exporter.WithCircuitBreaker(a2s.NewCircuitBreaker(*breaker, 30*time.Second, 10*time.Minute)))
+	}
+	if *dropLabels != "" {
+		opts = append(opts, exporter.WithoutLabels(strings.Split(*dropLabels, ",")...))
+	}
+	for _, spec := range limits {
+		name, n, err := parseLabelCount(spec)
+		if err != nil {
+			return fmt.Errorf("--label-limit: %w", err)
+		}
+		opts = append(opts, exporter.WithLabelLimit(name, n))
+	}
+	for _, spec := range hashed {
+		name, n, err := parseLabelCount(spec)
+		if err != nil {
+			return fmt.Errorf("--hash-label: %w", err)
+		}
+		opts = append(opts, exporter.WithHashedLabel(name, n))
+	}
+
+	e := exporter.New(*timeout, opts...)
+	mux := http.NewServeMux()
+	mux.Handle("/probe", e)
+	mux.Handle("/metrics", e.MetricsHandler())
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, "a2s exporter, probe servers at /probe?target=host:port, own metrics at /metrics")
+	})
+
+	fmt.Fprintf(os.Stderr, "a2s exporter listening on %s\n", *listen)
+	return http.ListenAndServe(*listen, mux)
+}
+
+// parseLabelCount parses a "label=n" flag value.
+func parseLabelCount(spec string) (string, int, error) {
+	name, count, ok := strings.Cut(spec, "=")
+	n, err := strconv.Atoi(count)
+	if !ok || name == "" || err != nil || n <= 0 {
+		return "", 0, fmt.Errorf("invalid value %q, expected label=n", spec)
+	}
+	return name, n, nil
+}
@@ -0,0 +1,343 @@
+//go:build !a2s_minimal
+
+// Command a2s queries Source and GoldSource game servers from the command
+// line.
+//
+// Usage:
+//
+//	a2s <command> [flags] <host:port>
+//
+// The commands are:
+//
+//	info        print the server info
+//	players     print the player list
+//	rules       print the server rules
+//	ping        measure the round-trip time to the server
+//	watch       show a live view of the map and players
+//	scan        find servers in IP ranges
+//	master      list servers from the master server
+//	bench       measure query latency, loss and response sizes
+//	bulk        query a list of servers and stream JSON lines
+//	changelog   list rule changes in bulk output collected over time
+//	exporter    serve a Prometheus exporter
+//	shell       run commands against a server interactively
+//	completion  print a shell completion script for bash, zsh or fish
+//
+// Every query command accepts --timeout and --retries, and --json to print
+// JSON instead of a table.
+//
+// With --check, info runs as a Nagios/Icinga plugin: it prints one line of
+// plugin output and exits with 0, 1, 2 or 3 for OK, WARNING, CRITICAL and
+// UNKNOWN, e.g.
+//
+//	a2s info --check --warn-time 200ms --crit-time 1s --crit-players 1: <host:port>
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// commands is filled in by init, since the shell command refers back to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"info", "print the server info", runInfo},
+		{"players", "print the player list", runPlayers},
+		{"rules", "print the server rules", runRules},
+		{"ping", "measure the round-trip time to the server", runPing},
+		{"watch", "show a live view of the map and players", runWatch},
+		{"scan", "find servers in IP ranges", runScan},
+		{"master", "list servers from the master server", runMaster},
+		{"bench", "measure query latency, loss and response sizes", runBench},
+		{"bulk", "query a list of servers and stream JSON lines", runBulk},
+		{"changelog", "list rule changes in bulk output collected over time", runChangelog},
+		{"exporter", "serve a Prometheus exporter", runExporter},
+		{"shell", "run commands against a server interactively", runShell},
+		{"completion", "print a shell completion script", runCompletion},
+	}
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// errUsage is returned by commands when their arguments are invalid, after
+// they have printed their usage.
+var errUsage = errors.New("usage")
+
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "a2s: unknown command %q\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(os.Args[2:])
+	var status exitStatus
+	if errors.As(err, &status) {
+		os.Exit(int(status))
+	}
+	if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "a2s %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: a2s <command> [flags] <host:port>")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'a2s <command> -h' for the flags of a command.")
+}
+
+
+// queryFlags are the flags shared by every command that queries a server.
+type queryFlags struct {
+	timeout time.Duration
+	retries int
+	json    bool
+	debug   bool
+	iface   string
+	srv     string
+	// local is the --local-addr flag as given, parsed into localAddr.
+	local     string
+	localAddr netip.AddrPort
+	// redact is the --redact flag as given, parsed into redaction.
+	redact    string
+	redaction *a2s.Redaction
+}
+
+func (f *queryFlags) register(fs *flag.FlagSet) {
+	fs.DurationVar(&f.timeout, "timeout", 3*time.Second, "timeout of each request")
+	fs.IntVar(&f.retries, "retries", 2, "how often to retry a request that timed out")
+	fs.BoolVar(&f.json, "json", false, "print JSON instead of a table")
+	fs.BoolVar(&f.debug, "debug", false, "log every packet to stderr as a hex dump")
+	fs.StringVar(&f.iface, "interface", "", "send queries through this network interface, e.g. a VPN")
+	fs.StringVar(&f.srv, "srv", "", "look up addresses without a port in SRV records of this `service`, e.g. a2s")
+	fs.Func("local-addr", "send queries from this local `address`, as ip, ip:port or :port", func(value string) (err error) {
+		f.local = value
+		f.localAddr, err = parseLocalAddr(value)
+		return err
+	})
+	fs.Func("redact", "hide `details` in the output: comma-separated addresses, steamids and passwords", func(value string) (err error) {
+		f.redact = value
+		f.redaction, err = parseRedaction(value)
+		return err
+	})
+}
+
+// options returns the client options selected by the flags, followed by opts.
+func (f *queryFlags) options(opts ...a2s.Option) []a2s.Option {
+	if r := f.redaction; r != nil {
+		opts = append([]a2s.Option{a2s.WithInfoTransform(r.Info), a2s.WithPlayersTransform(r.Players), a2s.WithRulesTransform(r.Rules)}, opts...)
+	}
+	if f.local != "" {
+		opts = append([]a2s.Option{a2s.WithLocalAddr(f.localAddr)}, opts...)
+	}
+	if f.srv != "" {
+		opts = append([]a2s.Option{a2s.WithSRV(f.srv)}, opts...)
+	}
+	if f.iface != "" {
+		opts = append([]a2s.Option{a2s.WithInterface(f.iface)}, opts...)
+	}
+	if f.debug {
+		handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+		opts = append([]a2s.Option{a2s.WithLogger(handler)}, opts...)
+	}
+	return opts
+}
+
+// parseLocalAddr parses the --local-addr flag, an IP address with or without
+// a port, or only a port.
+func parseLocalAddr(value string) (netip.AddrPort, error) {
+	if value == "" {
+		return netip.AddrPort{}, nil
+	}
+	if ip, err := netip.ParseAddr(strings.Trim(value, "[]")); err == nil {
+		return netip.AddrPortFrom(ip, 0), nil
+	}
+	if port, ok := strings.CutPrefix(value, ":"); ok {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return netip.AddrPort{}, fmt.Errorf("invalid port %q", port)
+		}
+		return netip.AddrPortFrom(netip.Addr{}, uint16(n)), nil
+	}
+	return netip.ParseAddrPort(value)
+}
+
+// parseRedaction parses the --redact flag. Empty values redact nothing.
+func parseRedaction(value string) (*a2s.Redaction, error) {
+	if value == "" {
+		return nil, nil
+	}
+	r := &a2s.Redaction{}
+	for _, name := range strings.Split(value, ",") {
+		switch strings.TrimSpace(name) {
+		case "addresses":
+			r.Addresses = true
+		case "steamids":
+			r.SteamIDs = true
+		case "passwords":
+			r.Patterns = append(r.Patterns, a2s.PasswordPattern)
+		default:
+			return nil, fmt.Errorf("unknown detail %q, want addresses, steamids or passwords", name)
+		}
+	}
+	return r, nil
+}
+
+// connect returns a client connected to addr.
+func (f *queryFlags) connect(addr string, opts ...a2s.Option) (*a2s.Client, error) {
+	client := a2s.NewClient(f.timeout, f.options(opts...)...)
+	if err := client.Connect(addr); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// retry calls fn until it succeeds, fails with an error other than a timeout,
+// or has been retried f.retries times.
+func (f *queryFlags) retry(fn func() error) error {
+	var err error
+	for attempt := 0; attempt <= f.retries; attempt++ {
+		if err = fn(); !errors.Is(err, a2s.ErrTimeout) {
+			return err
+		}
+	}
+	return err
+}
+
+
+// newFlagSet returns a flag set for the named command whose usage shows the
+// given positional arguments.
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: a2s %s [flags] %s\n\nFlags:\n", name, arguments)
+		fs.PrintDefaults()
+	}
+	if flagSetCreated != nil {
+		flagSetCreated(fs)
+	}
+	return fs
+}
+
+// flagSetCreated, if set, is called with every flag set newFlagSet returns.
+// It lets commandFlagSet see the flags of a command.
+var flagSetCreated func(fs *flag.FlagSet)
+
+// commandFlagSet returns the flag set of cmd, or nil if it has none. It runs
+// the command with -h, which every command handles by returning from parsing
+// its flags before doing anything else, and discards the usage it prints.
+func commandFlagSet(cmd *command) *flag.FlagSet {
+	var captured *flag.FlagSet
+	flagSetCreated = func(fs *flag.FlagSet) {
+		fs.SetOutput(io.Discard)
+		captured = fs
+	}
+	defer func() { flagSetCreated = nil }()
+
+	cmd.run([]string{"-h"})
+	return captured
+}
+
+// stringList is a flag that can be given multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseArgs parses flags that may appear before, between and after the
+// positional arguments, which the flag package alone does not allow, and
+// returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseAddr parses the arguments of a command that takes a single address.
+func parseAddr(fs *flag.FlagSet, args []string) (string, error) {
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return "", err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return "", errUsage
+	}
+	return positional[0], nil
+}
+
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
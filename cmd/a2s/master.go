@@ -0,0 +1,168 @@
+//go:build !a2s_private && !a2s_minimal
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/netip"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+
+	a2s "github.com/notedevil/valve-a2s"
+	"github.com/notedevil/valve-a2s/master"
+)
+
+
+var regions = map[string]master.Region{
+	"us-east":       master.RegionUSEast,
+	"us-west":       master.RegionUSWest,
+	"south-america": master.RegionSouthAmerica,
+	"eu":            master.RegionEurope,
+	"asia":          master.RegionAsia,
+	"australia":     master.RegionAustralia,
+	"middle-east":   master.RegionMiddleEast,
+	"africa":        master.RegionAfrica,
+	"world":         master.RegionWorld,
+}
+
+
+// runMaster lists servers from the master server. With --info every server
+// found is queried as well, through a Multiplexer, which gives a census of a
+// game's community in one command.
+func runMaster(args []string) error {
+	var flags queryFlags
+	fs := newFlagSet("master", "")
+	flags.register(fs)
+	addr := fs.String("master", master.DefaultAddr, "master server address")
+	appID := fs.Uint("appid", 0, "only list servers of this app ID")
+	regionName := fs.String("region", "world", "region: "+strings.Join(slices.Sorted(maps.Keys(regions)), ", "))
+	limit := fs.Int("limit", 0, "stop after this many servers, 0 for no limit")
+	queryInfo := fs.Bool("info", false, "query the info of every server found")
+	concurrency := fs.Int("concurrency", 256, "number of info queries in flight, with --info")
+	sockets := fs.Int("sockets", 4, "number of UDP sockets shared by info queries, with --info")
+	rate := fs.Float64("rate", 0, "maximum requests per second sent in total, 0 for no limit")
+	var filters stringList
+	fs.Var(&filters, "filter", "extra `key=value` filter condition, e.g. map=de_dust2 (repeatable)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	region, ok := regions[*regionName]
+	if !ok {
+		return fmt.Errorf("unknown region %q", *regionName)
+	}
+
+	filter := master.NewFilter()
+	if *appID != 0 {
+		filter.AppID(uint32(*appID))
+	}
+	for _, condition := range filters {
+		key, value, ok := strings.Cut(condition, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid filter %q, want key=value", condition)
+		}
+		filter.Condition(key, value)
+	}
+	query, err := filter.Build()
+	if err != nil {
+		return err
+	}
+
+	client := master.NewClient(flags.timeout, master.WithRetries(flags.retries))
+	if err := client.Connect(*addr); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if !*queryInfo {
+		return listServers(ctx, client, region, query, *limit, flags.json)
+	}
+
+	mux, err := a2s.NewMultiplexer(*sockets, flags.timeout, muxOptions(*rate)...)
+	if err != nil {
+		return err
+	}
+	defer mux.Close()
+
+	targets := make(chan netip.AddrPort)
+	var scanErr error
+	go func() {
+		defer close(targets)
+		scanErr = eachServer(ctx, client, region, query, *limit, func(server netip.AddrPort) bool {
+			select {
+			case targets <- server:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	results := probeAll(mux, &flags, targets, *concurrency)
+	if scanErr != nil {
+		return scanErr
+	}
+	if err := printServers(results, flags.json); err != nil {
+		return err
+	}
+	if !flags.json {
+		players := 0
+		for _, result := range results {
+			players += int(result.Info.Players) - int(result.Info.Bots)
+		}
+		fmt.Printf("\n%d servers answered, %d players online\n", len(results), players)
+	}
+	return nil
+}
+
+// listServers prints the addresses returned by the master server as they
+// arrive.
+func listServers(ctx context.Context, client *master.Client, region master.Region, query string, limit int, asJSON bool) error {
+	servers := []netip.AddrPort{}
+	err := eachServer(ctx, client, region, query, limit, func(server netip.AddrPort) bool {
+		if asJSON {
+			servers = append(servers, server)
+		} else {
+			fmt.Println(server)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return printJSON(servers)
+	}
+	return nil
+}
+
+// eachServer calls fn for the servers returned by the master server until it
+// returns false, limit servers have been seen or ctx is done.
+func eachServer(ctx context.Context, client *master.Client, region master.Region, query string, limit int, fn func(netip.AddrPort) bool) error {
+	count := 0
+	for server, err := range client.Servers(region, query) {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil || !fn(server) {
+			return nil
+		}
+		count++
+		if limit > 0 && count >= limit {
+			return nil
+		}
+	}
+	return nil
+}
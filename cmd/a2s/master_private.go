@@ -0,0 +1,12 @@
+//go:build a2s_private && !a2s_minimal
+
+package main
+
+import "errors"
+
+
+// runMaster is disabled in builds with the a2s_private tag, which must not
+// contact the master server.
+func runMaster(args []string) error {
+	return errors.New("master server queries are disabled in this build (a2s_private)")
+}
@@ -0,0 +1,201 @@
+//go:build !a2s_minimal
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+func runInfo(args []string) error {
+	var (
+		flags queryFlags
+		check checkFlags
+	)
+	fs := newFlagSet("info", "<host:port>")
+	flags.register(fs)
+	check.register(fs)
+	addr, err := parseAddr(fs, args)
+	if check.enabled {
+		if errors.Is(err, errUsage) {
+			return checkResult(statusUnknown, "expected a single <host:port> argument", "")
+		}
+		if err != nil {
+			return checkResult(statusUnknown, err.Error(), "")
+		}
+		return runCheck(&flags, &check, addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	client, err := flags.connect(addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var info *a2s.ServerInfo
+	err = flags.retry(func() (err error) {
+		info, err = client.GetInfo()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return printJSON(info)
+	}
+
+	w := newTable()
+	fmt.Fprintf(w, "Name:\t%s\n", info.Name)
+	fmt.Fprintf(w, "Map:\t%s\n", info.Map)
+	fmt.Fprintf(w, "Game:\t%s (%s)\n", info.Game, info.Folder)
+	fmt.Fprintf(w, "App ID:\t%d\n", info.AppID)
+	fmt.Fprintf(w, "Players:\t%d/%d (%d bots)\n", info.Players, info.MaxPlayers, info.Bots)
+	fmt.Fprintf(w, "Type:\t%s\n", info.ServerType)
+	fmt.Fprintf(w, "Environment:\t%s\n", info.Environment)
+	fmt.Fprintf(w, "Visibility:\t%s\n", info.Visibility)
+	fmt.Fprintf(w, "VAC:\t%s\n", info.VAC)
+	if info.Version != "" {
+		fmt.Fprintf(w, "Version:\t%s\n", info.Version)
+	}
+	if len(info.Keywords) > 0 {
+		fmt.Fprintf(w, "Keywords:\t%s\n", strings.Join(info.Keywords, ", "))
+	}
+	return w.Flush()
+}
+
+
+func runPlayers(args []string) error {
+	var flags queryFlags
+	fs := newFlagSet("players", "<host:port>")
+	flags.register(fs)
+	addr, err := parseAddr(fs, args)
+	if err != nil {
+		return err
+	}
+
+	client, err := flags.connect(addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var players []a2s.PlayerInfo
+	err = flags.retry(func() (err error) {
+		players, err = client.GetPlayers()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return printJSON(players)
+	}
+
+	w := newTable()
+	fmt.Fprintln(w, "NAME\tSCORE\tTIME")
+	for _, player := range players {
+		duration := time.Duration(player.Duration) * time.Second
+		fmt.Fprintf(w, "%s\t%d\t%s\n", player.Name, player.Score, duration)
+	}
+	return w.Flush()
+}
+
+
+func runRules(args []string) error {
+	var flags queryFlags
+	fs := newFlagSet("rules", "<host:port>")
+	flags.register(fs)
+	addr, err := parseAddr(fs, args)
+	if err != nil {
+		return err
+	}
+
+	client, err := flags.connect(addr, a2s.WithSortedRules())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var rules a2s.Rules
+	err = flags.retry(func() (err error) {
+		rules, err = client.GetRules()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return printJSON(rules)
+	}
+
+	w := newTable()
+	fmt.Fprintln(w, "NAME\tVALUE")
+	for _, rule := range rules {
+		fmt.Fprintf(w, "%s\t%s\n", rule.Name, rule.Value)
+	}
+	return w.Flush()
+}
+
+
+func runPing(args []string) error {
+	var flags queryFlags
+	fs := newFlagSet("ping", "<host:port>")
+	flags.register(fs)
+	count := fs.Int("count", 1, "number of probes to send")
+	interval := fs.Duration("interval", time.Second, "time between probes")
+	addr, err := parseAddr(fs, args)
+	if err != nil {
+		return err
+	}
+
+	client, err := flags.connect(addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if *count <= 1 {
+		var rtt time.Duration
+		err = flags.retry(func() (err error) {
+			rtt, err = client.RTT()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if flags.json {
+			return printJSON(map[string]any{"rtt_ms": float64(rtt) / float64(time.Millisecond)})
+		}
+		fmt.Println(rtt.Round(time.Microsecond))
+		return nil
+	}
+
+	stats, err := client.PingStats(*count, *interval)
+	if err != nil {
+		return err
+	}
+	if flags.json {
+		return printJSON(stats)
+	}
+
+	w := newTable()
+	fmt.Fprintf(w, "Sent:\t%d\n", stats.Sent)
+	fmt.Fprintf(w, "Received:\t%d (%.1f%% loss)\n", stats.Received, stats.Loss)
+	if stats.Received > 0 {
+		fmt.Fprintf(w, "Min/Avg/Max:\t%s / %s / %s\n", stats.Min.Round(time.Microsecond), stats.Avg.Round(time.Microsecond), stats.Max.Round(time.Microsecond))
+		fmt.Fprintf(w, "P95:\t%s\n", stats.P95.Round(time.Microsecond))
+		fmt.Fprintf(w, "Jitter:\t%s\n", stats.Jitter.Round(time.Microsecond))
+	}
+	return w.Flush()
+}
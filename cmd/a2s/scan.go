@@ -0,0 +1,232 @@
+//go:build !a2s_minimal
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/netip"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+type scanResult struct {
+	Address string          `json:"address"`
+	Key     a2s.ServerKey   `json:"key"`
+	Info    *a2s.ServerInfo `json:"info"`
+	// addr is the address before redaction, for sorting.
+	addr netip.AddrPort
+}
+
+
+// runScan probes every port of every host in the given networks and prints
+// the servers that answered A2S_INFO. All probes share the sockets of a
+// Multiplexer, so large ranges do not run out of file descriptors.
+func runScan(args []string) error {
+	var flags queryFlags
+	fs := newFlagSet("scan", "<cidr|ip>...")
+	flags.register(fs)
+	portList := fs.String("ports", "27015", "ports to probe, e.g. 27015-27030,27040")
+	concurrency := fs.Int("concurrency", 256, "number of probes in flight")
+	sockets := fs.Int("sockets", 4, "number of UDP sockets shared by all probes")
+	rate := fs.Float64("rate", 0, "maximum requests per second sent in total, 0 for no limit")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var prefixes []netip.Prefix
+	for _, arg := range positional {
+		prefix, err := parsePrefix(arg)
+		if err != nil {
+			return err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	ports, err := parsePorts(*portList)
+	if err != nil {
+		return err
+	}
+
+	mux, err := a2s.NewMultiplexer(*sockets, flags.timeout, muxOptions(*rate)...)
+	if err != nil {
+		return err
+	}
+	defer mux.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	targets := make(chan netip.AddrPort)
+	go func() {
+		defer close(targets)
+		for _, prefix := range prefixes {
+			for addr := range hosts(prefix) {
+				for _, port := range ports {
+					select {
+					case targets <- netip.AddrPortFrom(addr, port):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	results := probeAll(mux, &flags, targets, *concurrency)
+	return printServers(results, flags.json)
+}
+
+// probeAll probes the targets with the given number of workers and returns
+// the servers that answered, sorted by address.
+func probeAll(mux *a2s.Multiplexer, flags *queryFlags, targets <-chan netip.AddrPort, concurrency int) []scanResult {
+	var (
+		mu      sync.Mutex
+		results = []scanResult{}
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < max(concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range targets {
+				info, ok := probe(mux, flags, target)
+				if !ok {
+					continue
+				}
+				mu.Lock()
+				results = append(results, scanResult{
+					Address: flags.redaction.Address(target.String()),
+					Key:     flags.redaction.Key(a2s.NewServerKey(info, target)),
+					Info:    info,
+					addr:    target,
+				})
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	slices.SortFunc(results, func(a, b scanResult) int {
+		return a.addr.Compare(b.addr)
+	})
+	return results
+}
+
+func printServers(results []scanResult, asJSON bool) error {
+	if asJSON {
+		return printJSON(results)
+	}
+
+	w := newTable()
+	fmt.Fprintln(w, "ADDRESS\tNAME\tMAP\tPLAYERS")
+	for _, result := range results {
+		info := result.Info
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\n", result.Address, info.Name, info.Map, info.Players, info.MaxPlayers)
+	}
+	return w.Flush()
+}
+
+// muxOptions returns the multiplexer options for the --rate flag. Bursts of
+// up to a tenth of a second's worth of requests are allowed.
+func muxOptions(rate float64) []a2s.MultiplexerOption {
+	if rate <= 0 {
+		return nil
+	}
+	return []a2s.MultiplexerOption{a2s.WithGlobalRate(rate, int(rate/10))}
+}
+
+// probe queries the server info of a single target. Targets that do not
+// answer, or cannot be reached at all, are reported as not ok.
+func probe(mux *a2s.Multiplexer, flags *queryFlags, target netip.AddrPort) (*a2s.ServerInfo, bool) {
+	client, err := mux.ClientAddrPort(target, flags.options()...)
+	if err != nil {
+		return nil, false
+	}
+	defer client.Close()
+
+	var info *a2s.ServerInfo
+	err = flags.retry(func() (err error) {
+		info, err = client.GetInfo()
+		return err
+	})
+	return info, err == nil
+}
+
+
+// parsePrefix parses a CIDR prefix, or a single IP address as a prefix
+// holding only that address.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
+// parsePorts parses a comma-separated list of ports and port ranges.
+func parsePorts(s string) ([]uint16, error) {
+	var ports []uint16
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		if !isRange {
+			last = first
+		}
+		from, err := strconv.ParseUint(first, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", first)
+		}
+		to, err := strconv.ParseUint(last, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", last)
+		}
+		if from == 0 || from > to {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		for port := from; port <= to; port++ {
+			ports = append(ports, uint16(port))
+		}
+	}
+	return ports, nil
+}
+
+// hosts yields every address in the prefix. The network and broadcast
+// addresses of IPv4 networks larger than /31 are skipped.
+func hosts(prefix netip.Prefix) iter.Seq[netip.Addr] {
+	return func(yield func(netip.Addr) bool) {
+		skipEnds := prefix.Addr().Is4() && prefix.Bits() < 31
+
+		for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+			if skipEnds {
+				if addr == prefix.Addr() {
+					continue
+				}
+				if next := addr.Next(); !next.IsValid() || !prefix.Contains(next) {
+					return
+				}
+			}
+			if !yield(addr) {
+				return
+			}
+		}
+	}
+}
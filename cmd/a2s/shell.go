@@ -0,0 +1,125 @@
+//go:build !a2s_minimal
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+)
+
+
+// shellCommands are the commands that can be run against the target from the
+// interactive shell.
+var shellCommands = []string{"info", "players", "rules", "ping", "watch"}
+
+
+// runShell reads commands from standard input and runs them against a target
+// set once, either as argument or with the "target" command. The --timeout
+// and --retries flags given to the shell apply to every command, and can be
+// overridden per command.
+func runShell(args []string) error {
+	var flags queryFlags
+	fs := newFlagSet("shell", "[host:port]")
+	flags.register(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var target string
+	if len(positional) == 1 {
+		target = positional[0]
+	}
+	defaults := []string{
+		"--timeout=" + flags.timeout.String(),
+		fmt.Sprintf("--retries=%d", flags.retries),
+		fmt.Sprintf("--debug=%t", flags.debug),
+		"--interface=" + flags.iface,
+		"--srv=" + flags.srv,
+		"--local-addr=" + flags.local,
+		"--redact=" + flags.redact,
+	}
+
+	// Interrupts only stop the running command, such as watch, but never the
+	// shell itself.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		if target != "" {
+			fmt.Fprintf(os.Stderr, "a2s %s> ", target)
+		} else {
+			fmt.Fprint(os.Stderr, "a2s> ")
+		}
+		if !scanner.Scan() {
+			fmt.Fprintln(os.Stderr)
+			return scanner.Err()
+		}
+
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 {
+			continue
+		}
+
+		name, rest := words[0], words[1:]
+		switch name {
+		case "exit", "quit":
+			return nil
+		case "help", "?":
+			shellUsage()
+			continue
+		case "target", "connect":
+			if len(rest) != 1 {
+				fmt.Fprintf(os.Stderr, "usage: %s <host:port>\n", name)
+				continue
+			}
+			target = rest[0]
+			continue
+		}
+
+		cmd := findCommand(name)
+		if cmd == nil || !slices.Contains(shellCommands, name) {
+			fmt.Fprintf(os.Stderr, "unknown command %q, try help\n", name)
+			continue
+		}
+		if target == "" {
+			fmt.Fprintln(os.Stderr, "no target set, use: target <host:port>")
+			continue
+		}
+
+		// Flags given on the line come after the defaults, so they win.
+		err := cmd.run(slices.Concat(defaults, rest, []string{target}))
+		if err != nil && !errors.Is(err, errUsage) && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		}
+
+		// Drop the interrupt that may have ended the command.
+		select {
+		case <-interrupts:
+		default:
+		}
+	}
+}
+
+func shellUsage() {
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintf(os.Stderr, "  %-12s %s\n", "target", "set the server to query")
+	for _, name := range shellCommands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, findCommand(name).summary)
+	}
+	fmt.Fprintf(os.Stderr, "  %-12s %s\n", "exit", "leave the shell")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags can be given after a command, e.g. 'players --json'.")
+}
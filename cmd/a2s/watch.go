@@ -0,0 +1,193 @@
+//go:build !a2s_minimal
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+const (
+	ansiClear  = "\x1b[H\x1b[2J"
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+// watchState is what the watch view remembers between polls to highlight
+// changes.
+type watchState struct {
+	info    *a2s.ServerInfo
+	players []a2s.PlayerInfo
+	updated time.Time
+
+	joined      []string
+	left        []string
+	previousMap string
+}
+
+
+// runWatch polls a server until interrupted and redraws a live view of the
+// map and player list. Players are matched by name, since A2S has no stable
+// player identifier.
+func runWatch(args []string) error {
+	var flags queryFlags
+	fs := newFlagSet("watch", "<host:port>")
+	flags.register(fs)
+	interval := fs.Duration("interval", 5*time.Second, "time between polls")
+	noColor := fs.Bool("no-color", os.Getenv("NO_COLOR") != "", "disable colors")
+	addr, err := parseAddr(fs, args)
+	if err != nil {
+		return err
+	}
+	if flags.json {
+		return fmt.Errorf("--json is not supported by watch")
+	}
+
+	client, err := flags.connect(addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	var state watchState
+	for {
+		var (
+			info    *a2s.ServerInfo
+			players []a2s.PlayerInfo
+		)
+		err := flags.retry(func() (err error) {
+			if info, err = client.GetInfo(); err != nil {
+				return err
+			}
+			players, err = client.GetPlayers()
+			return err
+		})
+		if err == nil {
+			state.update(info, players)
+		}
+
+		var b strings.Builder
+		b.WriteString(ansiClear)
+		state.render(&b, addr, !*noColor)
+		if err != nil {
+			fmt.Fprintf(&b, "\n%s  %v\n", time.Now().Format(time.TimeOnly), err)
+		}
+		io.WriteString(os.Stdout, b.String())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+
+// update records a new poll result and works out which players joined or left
+// and whether the map changed since the previous one.
+func (s *watchState) update(info *a2s.ServerInfo, players []a2s.PlayerInfo) {
+	s.joined, s.left, s.previousMap = nil, nil, ""
+
+	if s.info != nil {
+		s.joined = diffNames(players, s.players)
+		s.left = diffNames(s.players, players)
+		if s.info.Map != info.Map {
+			s.previousMap = s.info.Map
+		}
+	}
+
+	s.info = info
+	s.players = players
+	s.updated = time.Now()
+}
+
+// diffNames returns the names of the players in a that are not in b. Names are
+// counted, so a second player with a taken name is still reported.
+func diffNames(a, b []a2s.PlayerInfo) []string {
+	remaining := make([]string, 0, len(b))
+	for _, player := range b {
+		remaining = append(remaining, player.Name)
+	}
+
+	var names []string
+	for _, player := range a {
+		if i := slices.Index(remaining, player.Name); i >= 0 {
+			remaining = slices.Delete(remaining, i, i+1)
+			continue
+		}
+		names = append(names, player.Name)
+	}
+	return names
+}
+
+
+func (s *watchState) render(w io.Writer, addr string, color bool) {
+	paint := func(code, text string) string {
+		if !color {
+			return text
+		}
+		return code + text + ansiReset
+	}
+
+	if s.info == nil {
+		fmt.Fprintf(w, "Waiting for %s ...\n", addr)
+		return
+	}
+
+	fmt.Fprintf(w, "%s  (%s, updated %s)\n\n", paint(ansiBold, s.info.Name), addr, s.updated.Format(time.TimeOnly))
+
+	mapLine := s.info.Map
+	if s.previousMap != "" {
+		mapLine = paint(ansiYellow, fmt.Sprintf("%s (changed from %s)", s.info.Map, s.previousMap))
+	}
+	fmt.Fprintf(w, "Map:      %s\n", mapLine)
+	fmt.Fprintf(w, "Players:  %d/%d (%d bots)\n\n", s.info.Players, s.info.MaxPlayers, s.info.Bots)
+
+	// Colors are applied to padded cells by hand, tabwriter would count the
+	// escape codes as part of the cell width.
+	width := len("NAME")
+	for _, player := range s.players {
+		width = max(width, utf8.RuneCountInString(player.Name))
+	}
+	for _, name := range s.left {
+		width = max(width, utf8.RuneCountInString(name))
+	}
+
+	fmt.Fprintf(w, "  %-*s  %6s  %s\n", width, "NAME", "SCORE", "TIME")
+	joined := slices.Clone(s.joined)
+	for _, player := range s.players {
+		marker, code := " ", ""
+		if i := slices.Index(joined, player.Name); i >= 0 {
+			joined = slices.Delete(joined, i, i+1)
+			marker, code = "+", ansiGreen
+		}
+		duration := time.Duration(player.Duration) * time.Second
+		row := fmt.Sprintf("%s %-*s  %6d  %s", marker, width, player.Name, player.Score, duration)
+		if code != "" {
+			row = paint(code, row)
+		}
+		fmt.Fprintln(w, row)
+	}
+	for _, name := range s.left {
+		fmt.Fprintln(w, paint(ansiRed, fmt.Sprintf("- %-*s", width, name)))
+	}
+}
@@ -0,0 +1,83 @@
+//go:build !js
+
+package a2s
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+
+// GetInfoContext is like GetInfo, but gives up when ctx is done. A deadline
+// of ctx shorter than the client timeout overrides it for this call only.
+func (c *Client) GetInfoContext(ctx context.Context) (*ServerInfo, error) {
+	defer c.withContext(ctx)()
+	return c.GetInfo()
+}
+
+// GetPlayersContext is like GetPlayers, but gives up when ctx is done. See
+// GetInfoContext.
+func (c *Client) GetPlayersContext(ctx context.Context) ([]PlayerInfo, error) {
+	defer c.withContext(ctx)()
+	return c.GetPlayers()
+}
+
+// GetRulesContext is like GetRules, but gives up when ctx is done. See
+// GetInfoContext. Give the client a generous timeout and the info query a
+// short deadline if rules of modded servers take long to arrive:
+//
+//	client := a2s.NewClient(10 * time.Second)
+//	...
+//	ctx, cancel := context.WithTimeout(ctx, time.Second)
+//	info, err := client.GetInfoContext(ctx)
+//	cancel()
+//	...
+//	rules, err := client.GetRules()
+func (c *Client) GetRulesContext(ctx context.Context) (Rules, error) {
+	defer c.withContext(ctx)()
+	return c.GetRules()
+}
+
+// QueryAllContext is like QueryAll, but gives up when ctx is done. The
+// deadline of ctx bounds the whole snapshot, not each query.
+func (c *Client) QueryAllContext(ctx context.Context) (*ServerSnapshot, error) {
+	defer c.withContext(ctx)()
+	return c.QueryAll()
+}
+
+
+// withContext makes ctx the context of the exchanges until the returned
+// function is called.
+func (c *Client) withContext(ctx context.Context) func() {
+	previous := c.ctx
+	c.ctx = ctx
+	return func() {
+		c.ctx = previous
+	}
+}
+
+// contextTimeout cuts the timeout of an exchange short to the deadline of the
+// current context. It returns ErrTimeout if the deadline has passed and the
+// context error if it was canceled.
+func (c *Client) contextTimeout(timeout time.Duration) (time.Duration, error) {
+	if c.ctx == nil {
+		return timeout, nil
+	}
+	if err := c.ctx.Err(); err != nil {
+		return 0, contextError(err)
+	}
+	if deadline, ok := c.ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	return timeout, nil
+}
+
+// contextError maps an expired deadline to ErrTimeout, so callers checking
+// for timeouts need not tell where the deadline came from.
+func contextError(err error) error {
+	if err == context.DeadlineExceeded {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+// Command cshared builds this package as a C shared library, so tools written
+// in other languages (Python panels, Rust bots, ...) can reuse the Go
+// implementation through a plain C ABI:
+//
+//	go build -buildmode=c-shared -o liba2s.so ./cshared
+//
+// This also writes liba2s.h with the exported declarations. Every query
+// function takes a "host:port" address and a timeout in milliseconds, and
+// returns a JSON document. On failure the document is {"error": "..."}.
+// Returned strings are allocated with malloc and must be released with
+// A2SFree.
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"time"
+	"unsafe"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+//export QueryInfo
+func QueryInfo(addr *C.char, timeoutMillis C.int) *C.char {
+	return query(addr, timeoutMillis, func(c *a2s.Client) (any, error) {
+		return c.GetInfo()
+	})
+}
+
+//export QueryPlayers
+func QueryPlayers(addr *C.char, timeoutMillis C.int) *C.char {
+	return query(addr, timeoutMillis, func(c *a2s.Client) (any, error) {
+		return c.GetPlayers()
+	})
+}
+
+//export QueryRules
+func QueryRules(addr *C.char, timeoutMillis C.int) *C.char {
+	return query(addr, timeoutMillis, func(c *a2s.Client) (any, error) {
+		return c.GetRules()
+	})
+}
+
+// A2SFree releases a string returned by one of the query functions.
+//
+//export A2SFree
+func A2SFree(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+
+// query connects to the server, runs fn and encodes its result, or the error,
+// as a JSON C string.
+func query(addr *C.char, timeoutMillis C.int, fn func(*a2s.Client) (any, error)) *C.char {
+	client := a2s.NewClient(time.Duration(timeoutMillis) * time.Millisecond)
+	defer client.Close()
+
+	if err := client.Connect(C.GoString(addr)); err != nil {
+		return encodeError(err)
+	}
+
+	result, err := fn(client)
+	if err != nil {
+		return encodeError(err)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return encodeError(err)
+	}
+	return C.CString(string(data))
+}
+
+func encodeError(err error) *C.char {
+	data, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+	return C.CString(string(data))
+}
+
+
+func main() {}
@@ -0,0 +1,153 @@
+//go:build !js
+
+package a2s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+
+// DefaultPort is the query port of Source servers, used by WithSRV for hosts
+// without SRV records.
+const DefaultPort = 27015
+
+// DefaultSRVService is the service name of the SRV records that WithSRV looks
+// up if none is given, as in _a2s._udp.example.com.
+const DefaultSRVService = "a2s"
+
+
+// resolveAddrPorts resolves a "host:port" address with the resolver of the
+// client, or the default one. With WithSRV, a host without a port is looked
+// up in SRV records first. The first address returned is the preferred one,
+// IPv4 like net.ResolveUDPAddr. If the host has addresses of both families,
+// the first of the other family follows as an alternative for Happy
+// Eyeballs.
+func (c *Client) resolveAddrPorts(ctx context.Context, addr string) ([]netip.AddrPort, error) {
+	resolver := c.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	host, service, err := net.SplitHostPort(addr)
+	if err != nil {
+		if c.srvService == "" {
+			return nil, err
+		}
+		return c.resolveSRV(ctx, resolver, strings.Trim(addr, "[]"))
+	}
+	port, err := resolver.LookupPort(ctx, "udp", service)
+	if err != nil {
+		return nil, err
+	}
+	return lookupHost(ctx, resolver, host, uint16(port))
+}
+
+// resolveSRV resolves the target of the first SRV record of host, or host on
+// DefaultPort if it has none.
+func (c *Client) resolveSRV(ctx context.Context, resolver *net.Resolver, host string) ([]netip.AddrPort, error) {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return lookupHost(ctx, resolver, host, DefaultPort)
+	}
+
+	_, records, err := resolver.LookupSRV(ctx, c.srvService, "udp", host)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound || err == nil && len(records) == 0 {
+		return lookupHost(ctx, resolver, host, DefaultPort)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if records[0].Target == "." {
+		return nil, fmt.Errorf("%s: SRV records say there is no %s service", host, c.srvService)
+	}
+	return lookupHost(ctx, resolver, strings.TrimSuffix(records[0].Target, "."), records[0].Port)
+}
+
+// lookupHost resolves the host name, or parses the IP address, for
+// resolveAddrPorts.
+func lookupHost(ctx context.Context, resolver *net.Resolver, host string, port uint16) ([]netip.AddrPort, error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []netip.AddrPort{netip.AddrPortFrom(ip, port)}, nil
+	}
+	ips, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	var ipv4, ipv6 []netip.AddrPort
+	for _, ip := range ips {
+		if ip = ip.Unmap(); ip.Is4() {
+			ipv4 = append(ipv4, netip.AddrPortFrom(ip, port))
+		} else {
+			ipv6 = append(ipv6, netip.AddrPortFrom(ip, port))
+		}
+	}
+	switch {
+	case len(ipv4) > 0 && len(ipv6) > 0:
+		return []netip.AddrPort{ipv4[0], ipv6[0]}, nil
+	case len(ipv4) > 0:
+		return ipv4[:1], nil
+	default:
+		return ipv6[:1], nil
+	}
+}
+
+
+// reresolve resolves the host name given to Connect again if WithReresolve
+// asks for it, and re-dials if the server moved to an address it did not
+// resolve to before. If resolving fails, the client keeps using the old
+// address and tries again before the next query.
+func (c *Client) reresolve() {
+	if c.reresolveInterval < 0 || c.host == "" {
+		return
+	}
+	now := c.clock.Now()
+	if !c.resolveStale && now.Sub(c.resolvedAt) < c.reresolveInterval {
+		return
+	}
+
+	ctx := context.Background()
+	if c.ctx != nil {
+		ctx = c.ctx
+	}
+	if limit := c.budget.limit(phaseResolve); limit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limit)
+		defer cancel()
+	}
+	addrPorts, err := c.resolveAddrPorts(ctx, c.host)
+	if err != nil {
+		return
+	}
+	c.resolvedAt = now
+	c.resolveStale = false
+
+	current, _ := c.remoteAddrPort()
+	for _, addr := range addrPorts {
+		if addr == current {
+			return
+		}
+	}
+	conn, err := c.dialUDP(addrPorts[0])
+	if err != nil {
+		return
+	}
+
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if c.closed.Load() {
+		conn.Close()
+		return
+	}
+	c.conn.Close()
+	c.conn = conn
+	c.challenge = -1
+	c.cachedChallenge = false
+	c.srtt = 0
+	if c.eyeballsDelay > 0 {
+		c.candidates = addrPorts[1:]
+	}
+}
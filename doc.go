@@ -0,0 +1,63 @@
+// Package a2s implements a client for Valve's A2S server query protocol,
+// used by Source and GoldSource game servers to report their info, players
+// and rules.
+//
+// # API stability
+//
+// The query client (Client, Multiplexer and their options), the result types
+// and the errors in this package are the stable core: they follow semantic
+// versioning and will not change incompatibly within a major version.
+//
+// Subsystems built on top of the core live in their own subpackages and are
+// experimental until stated otherwise; their APIs may change between minor
+// releases:
+//
+//   - master: master server queries for discovering servers
+//   - rcon: remote console clients and output parsers
+//   - exporter: Prometheus exporter probing servers on demand
+//   - cache: memoizing client for frontends querying the same servers often
+//   - a2stest: in-process server for testing code that queries servers
+//
+// # Querying through a VPN
+//
+// Game servers in private networks, with RFC 1918 addresses reachable only
+// through a WireGuard or other VPN interface, are queried by binding the
+// client to the interface and resolving names with the DNS server inside the
+// VPN:
+//
+//	resolver := &net.Resolver{
+//		PreferGo: true,
+//		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+//			var d net.Dialer
+//			return d.DialContext(ctx, network, "10.8.0.1:53")
+//		},
+//	}
+//	client := a2s.NewClient(time.Second, a2s.WithInterface("wg0"), a2s.WithResolver(resolver))
+//	err := client.Connect("tf2.lan.example:27015")
+//
+// On Linux the socket is bound to the interface itself, so this works even if
+// the VPN does not install routes for the private network. Elsewhere the
+// socket is only bound to the address of the interface, which requires a
+// route through it.
+//
+// # Build profiles
+//
+// Building with the a2s_minimal tag, which TinyGo implies, leaves out
+// everything but the single-server Client and the decoder: no Multiplexer, no
+// Redaction and no reflection based encoding. This keeps the footprint small
+// enough for embedded status displays that poll a single game server. The a2s
+// command and the exporter subpackage need those and are not built in this
+// profile.
+//
+// Building with the a2s_private tag guarantees that nothing but the queried
+// servers is ever contacted: the master subpackage, the only code that talks
+// to Valve's infrastructure, fails to build, so no dependency can pull it in.
+// This package itself never sends packets anywhere else, with or without the
+// tag.
+//
+// Building with the a2s_debug tag makes clients that are garbage collected
+// without being closed print a warning with the stack that connected them.
+//
+// On GOOS=js only the net-free Decoder is available, for decoding packets in
+// the browser.
+package a2s
@@ -0,0 +1,182 @@
+package a2s
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+
+// EncodeInfo builds an S2A_INFO_SRC response packet, including its 0xFFFFFFFF
+// header, that DecodeInfo decodes back into info. The extra data flags are
+// taken from info.EDF, and set for every extra field that is not empty. The
+// Ship fields are encoded if info.AppID is AppIDTheShip. GoldSource only
+// fields are ignored.
+// It returns ErrUnencodable if a string contains a null byte.
+func EncodeInfo(info *ServerInfo) ([]byte, error) {
+	e := encoder{packet: headerPacket(S2A_INFO_SRC)}
+
+	e.byte(info.Protocol)
+	e.string("name", info.Name)
+	e.string("map", info.Map)
+	e.string("folder", info.Folder)
+	e.string("game", info.Game)
+	e.uint16(info.AppID)
+	e.byte(info.Players)
+	e.byte(info.MaxPlayers)
+	e.byte(info.Bots)
+	e.byte(byte(info.ServerType))
+	e.byte(byte(info.Environment))
+	e.byte(byte(info.Visibility))
+	e.byte(byte(info.VAC))
+	if info.AppID == AppIDTheShip {
+		e.byte(info.ShipMode)
+		e.byte(info.ShipWitnesses)
+		e.byte(info.ShipDuration)
+	}
+	e.string("version", info.Version)
+
+	edf := info.EDF
+	if info.GamePort != 0 {
+		edf |= 0x80
+	}
+	if info.SteamID != 0 {
+		edf |= 0x10
+	}
+	if info.SourceTV.Port != 0 || info.SourceTV.Name != "" {
+		edf |= 0x40
+	}
+	if len(info.Keywords) > 0 {
+		edf |= 0x20
+	}
+	if info.GameID != 0 {
+		edf |= 0x01
+	}
+	if edf == 0 {
+		return e.result()
+	}
+
+	e.byte(edf)
+	if edf&0x80 != 0 {
+		e.uint16(info.GamePort)
+	}
+	if edf&0x10 != 0 {
+		e.uint64(info.SteamID)
+	}
+	if edf&0x40 != 0 {
+		e.uint16(info.SourceTV.Port)
+		e.string("source tv name", info.SourceTV.Name)
+	}
+	if edf&0x20 != 0 {
+		for _, keyword := range info.Keywords {
+			if strings.Contains(keyword, ",") {
+				e.fail("keyword %q contains a comma", keyword)
+			}
+		}
+		e.string("keywords", strings.Join(info.Keywords, ","))
+	}
+	if edf&0x01 != 0 {
+		e.uint64(info.GameID)
+	}
+	return e.result()
+}
+
+// EncodePlayers builds an S2A_PLAYER response packet, including its
+// 0xFFFFFFFF header. For The Ship (appID AppIDTheShip) the deaths and money
+// of every player are appended, as DecodePlayers expects with that AppID.
+// It returns ErrUnencodable for more than 255 players or names containing a
+// null byte.
+func EncodePlayers(players []PlayerInfo, appID uint16) ([]byte, error) {
+	e := encoder{packet: headerPacket(S2A_PLAYER)}
+
+	if len(players) > math.MaxUint8 {
+		e.fail("%d players, at most %d fit", len(players), math.MaxUint8)
+	}
+	e.byte(byte(len(players)))
+	for _, player := range players {
+		e.byte(player.Index)
+		e.string("player name", player.Name)
+		e.uint32(uint32(player.Score))
+		e.uint32(math.Float32bits(player.Duration))
+	}
+
+	if appID == AppIDTheShip {
+		for _, player := range players {
+			e.uint32(uint32(player.Deaths))
+			e.uint32(uint32(player.Money))
+		}
+	}
+	return e.result()
+}
+
+// EncodeRules builds an S2A_RULES response packet, including its 0xFFFFFFFF
+// header, with the rules in the given order. Real servers split large rule
+// lists into several packets; EncodeRules always returns a single one.
+// It returns ErrUnencodable for more than 65535 rules or names and values
+// containing a null byte.
+func EncodeRules(rules Rules) ([]byte, error) {
+	e := encoder{packet: headerPacket(S2A_RULES)}
+
+	if len(rules) > math.MaxUint16 {
+		e.fail("%d rules, at most %d fit", len(rules), math.MaxUint16)
+	}
+	e.uint16(uint16(len(rules)))
+	for _, rule := range rules {
+		e.string("rule name", rule.Name)
+		e.string("rule value", rule.Value)
+	}
+	return e.result()
+}
+
+
+// encoder appends the fields of a response packet and remembers the first
+// value that cannot be encoded.
+type encoder struct {
+	packet []byte
+	err    error
+}
+
+func headerPacket(responseType byte) []byte {
+	packet := make([]byte, 0, 128)
+	packet = binary.LittleEndian.AppendUint32(packet, Header)
+	return append(packet, responseType)
+}
+
+func (e *encoder) fail(format string, args ...any) {
+	if e.err == nil {
+		e.err = fmt.Errorf("%w: "+format, append([]any{ErrUnencodable}, args...)...)
+	}
+}
+
+func (e *encoder) result() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.packet, nil
+}
+
+func (e *encoder) byte(b byte) {
+	e.packet = append(e.packet, b)
+}
+
+func (e *encoder) uint16(v uint16) {
+	e.packet = binary.LittleEndian.AppendUint16(e.packet, v)
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.packet = binary.LittleEndian.AppendUint32(e.packet, v)
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.packet = binary.LittleEndian.AppendUint64(e.packet, v)
+}
+
+// string appends a null-terminated string.
+func (e *encoder) string(field, s string) {
+	if strings.IndexByte(s, 0) >= 0 {
+		e.fail("%s %q contains a null byte", field, s)
+	}
+	e.packet = append(e.packet, s...)
+	e.packet = append(e.packet, 0)
+}
@@ -0,0 +1,59 @@
+package a2s
+
+import (
+	"reflect"
+	"testing"
+)
+
+
+func TestEncodeInfoRoundTrip(t *testing.T) {
+	full := &ServerInfo{
+		Protocol:    17,
+		Name:        "Test Server",
+		Map:         "de_dust2",
+		Folder:      "cstrike",
+		Game:        "Counter-Strike: Source",
+		AppID:       240,
+		Players:     12,
+		MaxPlayers:  24,
+		Bots:        2,
+		ServerType:  ServerTypeDedicated,
+		Environment: EnvironmentLinux,
+		Visibility:  VisibilityPrivate,
+		VAC:         VACSecured,
+		Version:     "1.0.0.71",
+		EDF:         0xF1,
+		GamePort:    27015,
+		SteamID:     90071996842377216,
+		Keywords:    []string{"alltalk", "nocrits"},
+		GameID:      240,
+	}
+	full.SourceTV.Port = 27020
+	full.SourceTV.Name = "SourceTV"
+
+	tests := []struct {
+		name string
+		info *ServerInfo
+	}{
+		{"empty", &ServerInfo{}},
+		{"minimal", &ServerInfo{Name: "test", Map: "de_dust2"}},
+		{"full", full},
+		{"the ship", &ServerInfo{Name: "ship", AppID: AppIDTheShip, ShipMode: 2, ShipWitnesses: 3, ShipDuration: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet, err := EncodeInfo(tt.info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var d Decoder
+			got, err := d.DecodeInfo(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.info) {
+				t.Errorf("DecodeInfo(EncodeInfo(info)) = %+v, want %+v", got, tt.info)
+			}
+		})
+	}
+}
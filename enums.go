@@ -0,0 +1,260 @@
+package a2s
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+
+// ServerType is the kind of server, as reported in A2S_INFO.
+type ServerType byte
+
+const (
+	ServerTypeDedicated ServerType = 'd'
+	ServerTypeListen    ServerType = 'l'
+	ServerTypeSourceTV  ServerType = 'p'
+)
+
+// Environment is the operating system a server runs on, as reported in
+// A2S_INFO.
+type Environment byte
+
+const (
+	EnvironmentLinux   Environment = 'l'
+	EnvironmentWindows Environment = 'w'
+	EnvironmentMac     Environment = 'm'
+	// EnvironmentMacOld is the code older servers send for macOS.
+	EnvironmentMacOld Environment = 'o'
+)
+
+// Visibility tells whether a server requires a password.
+type Visibility byte
+
+const (
+	VisibilityPublic  Visibility = 0
+	VisibilityPrivate Visibility = 1
+)
+
+// VACStatus tells whether a server is secured by Valve Anti-Cheat.
+type VACStatus byte
+
+const (
+	VACUnsecured VACStatus = 0
+	VACSecured   VACStatus = 1
+)
+
+
+// Normalize returns the lower case code Source servers use. GoldSource servers
+// send the same codes in upper case; decoded infos are normalized already.
+func (t ServerType) Normalize() ServerType {
+	if t >= 'A' && t <= 'Z' {
+		return t + 'a' - 'A'
+	}
+	return t
+}
+
+// String returns "dedicated", "listen" or "sourcetv". Unknown codes are
+// returned as ServerType(n), with n the decimal value of the code.
+func (t ServerType) String() string {
+	switch t.Normalize() {
+	case ServerTypeDedicated:
+		return "dedicated"
+	case ServerTypeListen:
+		return "listen"
+	case ServerTypeSourceTV:
+		return "sourcetv"
+	}
+	return fmt.Sprintf("ServerType(%d)", byte(t))
+}
+
+// ParseServerType parses a name returned by ServerType.String, including the
+// ServerType(120) form of unknown codes, or a raw single character code.
+func ParseServerType(s string) (ServerType, error) {
+	switch strings.ToLower(s) {
+	case "dedicated":
+		return ServerTypeDedicated, nil
+	case "listen":
+		return ServerTypeListen, nil
+	case "sourcetv":
+		return ServerTypeSourceTV, nil
+	}
+	if v, ok := parseUnknownCode(s, "ServerType"); ok {
+		return ServerType(v), nil
+	}
+	if len(s) == 1 {
+		return ServerType(s[0]), nil
+	}
+	return 0, fmt.Errorf("unknown server type %q", s)
+}
+
+func (t ServerType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *ServerType) UnmarshalText(text []byte) error {
+	v, err := ParseServerType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+
+// Normalize returns the lower case code Source servers use. GoldSource servers
+// send the same codes in upper case; decoded infos are normalized already.
+func (e Environment) Normalize() Environment {
+	if e >= 'A' && e <= 'Z' {
+		return e + 'a' - 'A'
+	}
+	return e
+}
+
+// IsMac reports whether the environment is macOS, under either code.
+func (e Environment) IsMac() bool {
+	e = e.Normalize()
+	return e == EnvironmentMac || e == EnvironmentMacOld
+}
+
+// String returns "linux", "windows" or "mac". Unknown codes are returned as
+// Environment(n), with n the decimal value of the code.
+func (e Environment) String() string {
+	switch n := e.Normalize(); {
+	case n == EnvironmentLinux:
+		return "linux"
+	case n == EnvironmentWindows:
+		return "windows"
+	case e.IsMac():
+		return "mac"
+	}
+	return fmt.Sprintf("Environment(%d)", byte(e))
+}
+
+// ParseEnvironment parses a name returned by Environment.String, including
+// the Environment(120) form of unknown codes, or a raw single character code.
+func ParseEnvironment(s string) (Environment, error) {
+	switch strings.ToLower(s) {
+	case "linux":
+		return EnvironmentLinux, nil
+	case "windows":
+		return EnvironmentWindows, nil
+	case "mac", "macos":
+		return EnvironmentMac, nil
+	}
+	if v, ok := parseUnknownCode(s, "Environment"); ok {
+		return Environment(v), nil
+	}
+	if len(s) == 1 {
+		return Environment(s[0]), nil
+	}
+	return 0, fmt.Errorf("unknown environment %q", s)
+}
+
+func (e Environment) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+func (e *Environment) UnmarshalText(text []byte) error {
+	v, err := ParseEnvironment(string(text))
+	if err != nil {
+		return err
+	}
+	*e = v
+	return nil
+}
+
+
+// String returns "public" or "private".
+func (v Visibility) String() string {
+	switch v {
+	case VisibilityPublic:
+		return "public"
+	case VisibilityPrivate:
+		return "private"
+	}
+	return fmt.Sprintf("Visibility(%d)", byte(v))
+}
+
+// ParseVisibility parses a name returned by Visibility.String, including the
+// Visibility(2) form of unknown values.
+func ParseVisibility(s string) (Visibility, error) {
+	switch strings.ToLower(s) {
+	case "public":
+		return VisibilityPublic, nil
+	case "private":
+		return VisibilityPrivate, nil
+	}
+	if v, ok := parseUnknownCode(s, "Visibility"); ok {
+		return Visibility(v), nil
+	}
+	return 0, fmt.Errorf("unknown visibility %q", s)
+}
+
+func (v Visibility) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *Visibility) UnmarshalText(text []byte) error {
+	parsed, err := ParseVisibility(string(text))
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+
+// String returns "secured" or "unsecured".
+func (s VACStatus) String() string {
+	switch s {
+	case VACUnsecured:
+		return "unsecured"
+	case VACSecured:
+		return "secured"
+	}
+	return fmt.Sprintf("VACStatus(%d)", byte(s))
+}
+
+// ParseVACStatus parses a name returned by VACStatus.String, including the
+// VACStatus(2) form of unknown values.
+func ParseVACStatus(s string) (VACStatus, error) {
+	switch strings.ToLower(s) {
+	case "unsecured":
+		return VACUnsecured, nil
+	case "secured":
+		return VACSecured, nil
+	}
+	if v, ok := parseUnknownCode(s, "VACStatus"); ok {
+		return VACStatus(v), nil
+	}
+	return 0, fmt.Errorf("unknown VAC status %q", s)
+}
+
+func (s VACStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *VACStatus) UnmarshalText(text []byte) error {
+	v, err := ParseVACStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+
+// parseUnknownCode parses the Type(n) form String returns for unknown codes.
+func parseUnknownCode(s, typ string) (byte, bool) {
+	s, ok := strings.CutPrefix(s, typ+"(")
+	if !ok {
+		return 0, false
+	}
+	s, ok = strings.CutSuffix(s, ")")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s, 10, 8)
+	return byte(n), err == nil
+}
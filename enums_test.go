@@ -0,0 +1,63 @@
+package a2s
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+
+func TestGoldSourceCodes(t *testing.T) {
+	if got := ServerType('D').String(); got != "dedicated" {
+		t.Errorf("ServerType('D') = %q, want dedicated", got)
+	}
+	for code, want := range map[Environment]string{'L': "linux", 'W': "windows", 'M': "mac", 'O': "mac"} {
+		if got := code.String(); got != want {
+			t.Errorf("Environment(%q) = %q, want %q", rune(code), got, want)
+		}
+	}
+}
+
+// TestGoldSourceInfoNormalized decodes a GoldSource info response, whose
+// codes are in upper case, and compares them to the Source constants.
+func TestGoldSourceInfoNormalized(t *testing.T) {
+	packet := []byte("\xFF\xFF\xFF\xFFm127.0.0.1:27015\x00Half-Life\x00crossfire\x00valve\x00Half-Life\x00")
+	packet = append(packet, 3, 16, 47, 'D', 'L', 0, 0, 1, 0)
+	info, err := new(Decoder).DecodeInfo(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ServerType != ServerTypeDedicated || info.Environment != EnvironmentLinux {
+		t.Errorf("decoded %q and %q, want %q and %q", rune(info.ServerType), rune(info.Environment), rune(ServerTypeDedicated), rune(EnvironmentLinux))
+	}
+}
+
+func TestEnumsJSONRoundTrip(t *testing.T) {
+	type enums struct {
+		ServerType  ServerType
+		Environment Environment
+		Visibility  Visibility
+		VAC         VACStatus
+	}
+	tests := []enums{
+		{ServerTypeDedicated, EnvironmentLinux, VisibilityPublic, VACSecured},
+		{ServerTypeSourceTV, EnvironmentMacOld, VisibilityPrivate, VACUnsecured},
+		{'x', 'y', 2, 7},
+		{0xC8, 0xC9, 0xCA, 0xCB},
+	}
+	for _, want := range tests {
+		data, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got enums
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+		if got.Environment.IsMac() && want.Environment.IsMac() {
+			got.Environment = want.Environment
+		}
+		if got != want {
+			t.Errorf("round trip of %+v through %s = %+v", want, data, got)
+		}
+	}
+}
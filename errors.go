@@ -13,6 +13,15 @@ var (
 	ErrTooManyRetries      = errors.New("too many retries")
 	ErrUnsupportedFeature  = errors.New("unsupported feature")
 	ErrShortResponse       = errors.New("response too short")
+	ErrMultiplexerClosed   = errors.New("multiplexer closed")
+	ErrTargetRegistered    = errors.New("target already registered")
+	ErrRuleNotFound        = errors.New("rule not found")
+	ErrChecksumMismatch    = errors.New("checksum mismatch")
+	ErrClientClosed        = errors.New("client closed")
+	ErrUnencodable         = errors.New("value cannot be encoded")
+	ErrCircuitOpen         = errors.New("circuit open, server keeps timing out")
+	ErrValidation          = errors.New("response rejected by validator")
+	ErrProxyFailed         = errors.New("proxy failed")
 )
 
 type ProtocolError struct {
@@ -22,4 +31,22 @@ type ProtocolError struct {
 
 func (e *ProtocolError) Error() string {
 	return fmt.Sprintf("unexpected response type: 0x%X, expected: 0x%X", e.Actual, e.Expected)
-}
\ No newline at end of file
+}
+
+// CoercionError is returned by Rules.GetInt and Rules.GetFloat when the rule's
+// value is not a number of the requested type. Value holds the raw string the
+// server sent.
+type CoercionError struct {
+	Rule  string
+	Value string
+	Type  string
+	Err   error
+}
+
+func (e *CoercionError) Error() string {
+	return fmt.Sprintf("rule %s: cannot convert %q to %s", e.Rule, e.Value, e.Type)
+}
+
+func (e *CoercionError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,328 @@
+//go:build !tinygo && !a2s_minimal
+
+// Package exporter implements a Prometheus exporter for game servers in the
+// style of the blackbox exporter: every scrape of the probe endpoint queries
+// the server given in the target parameter, e.g.
+//
+//	/probe?target=203.0.113.10:27015
+//
+// and returns its metrics in the Prometheus text format.
+//
+// This package is experimental: its API may change between minor releases.
+// It is not available in the minimal profile, which has no Redaction.
+package exporter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+// Exporter answers probe requests. It implements http.Handler and is usually
+// mounted at /probe.
+type Exporter struct {
+	timeout time.Duration
+	rules   []string
+
+	breaker   *a2s.CircuitBreaker
+	redaction *a2s.Redaction
+
+	mu      sync.Mutex
+	labels  map[string]*labelPolicy
+	latency map[latencyKey]*histogram
+	// Probes so far, by whether the server answered.
+	succeeded uint64
+	failed    uint64
+}
+
+// Option configures an Exporter.
+type Option func(*Exporter)
+
+// WithRules exports the values of the named rules (cvars) as a2s_rule gauges.
+// Only numeric values are exported. Rules are only queried if at least one is
+// configured.
+func WithRules(names ...string) Option {
+	return func(e *Exporter) {
+		e.rules = append(e.rules, names...)
+	}
+}
+
+// WithoutLabels leaves the named labels out of a2s_info. The labels are
+// name, map, game, folder, version, server_type and environment.
+func WithoutLabels(names ...string) Option {
+	return func(e *Exporter) {
+		for _, name := range names {
+			e.policy(name).drop = true
+		}
+	}
+}
+
+// WithLabelLimit caps the number of distinct values of the named a2s_info
+// label, e.g. the map on networks that rotate through hundreds of custom
+// maps. The first limit values seen are exported as they are, any later ones
+// as "other". The values are remembered for the lifetime of the exporter.
+func WithLabelLimit(name string, limit int) Option {
+	return func(e *Exporter) {
+		e.policy(name).limit = limit
+	}
+}
+
+// WithHashedLabel replaces the values of the named a2s_info label with one of
+// the given number of hash buckets, exported as "bucket-7" and so on. This
+// bounds the cardinality while still telling apart most values, e.g. server
+// versions, without remembering them like WithLabelLimit does.
+func WithHashedLabel(name string, buckets int) Option {
+	return func(e *Exporter) {
+		e.policy(name).buckets = buckets
+	}
+}
+
+// WithCircuitBreaker makes probes of servers that keep timing out fail right
+// away, see a2s.CircuitBreaker, and adds its state to the exporter's own
+// metrics.
+func WithCircuitBreaker(breaker *a2s.CircuitBreaker) Option {
+	return func(e *Exporter) {
+		e.breaker = breaker
+	}
+}
+
+// WithRedaction redacts the info of servers before it is exported, for
+// exporters whose metrics end up on public dashboards, see a2s.Redaction.
+func WithRedaction(redaction *a2s.Redaction) Option {
+	return func(e *Exporter) {
+		e.redaction = redaction
+	}
+}
+
+// New returns an exporter whose queries use the given timeout.
+func New(timeout time.Duration, opts ...Option) *Exporter {
+	e := &Exporter{
+		timeout: timeout,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+
+// ServeHTTP probes the server given in the target query parameter. A server
+// that does not answer is reported with a2s_up 0, not as an HTTP error, so
+// Prometheus keeps scraping it.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.Probe(w, target)
+}
+
+// Probe queries the target and writes its metrics to w in the Prometheus text
+// format.
+func (e *Exporter) Probe(w io.Writer, target string) error {
+	start := time.Now()
+	m := &metricWriter{w: w}
+
+	var clientOpts []a2s.Option
+	if e.breaker != nil {
+		clientOpts = append(clientOpts, a2s.WithCircuitBreaker(e.breaker))
+	}
+	if e.redaction != nil {
+		clientOpts = append(clientOpts, a2s.WithInfoTransform(e.redaction.Info))
+	}
+	client := a2s.NewClient(e.timeout, clientOpts...)
+	defer client.Close()
+
+	var info *a2s.ServerInfo
+	err := client.Connect(target)
+	if err == nil {
+		info, err = client.GetInfo()
+	}
+	e.countProbe(err == nil)
+	if err != nil {
+		m.gauge("a2s_up", "Whether the server answered A2S_INFO.", nil, 0)
+		m.gauge("a2s_probe_duration_seconds", "How long the probe took.", nil, time.Since(start).Seconds())
+		return m.err
+	}
+
+	m.gauge("a2s_up", "Whether the server answered A2S_INFO.", nil, 1)
+	m.gauge("a2s_info", "Server info, the value is always 1.", e.applyPolicies([]label{
+		{"name", info.Name},
+		{"map", info.Map},
+		{"game", info.Game},
+		{"folder", info.Folder},
+		{"version", info.Version},
+		{"server_type", info.ServerType.String()},
+		{"environment", info.Environment.String()},
+	}), 1)
+	m.gauge("a2s_players", "Number of players on the server, including bots.", nil, float64(info.Players))
+	m.gauge("a2s_max_players", "Maximum number of players the server allows.", nil, float64(info.MaxPlayers))
+	m.gauge("a2s_bots", "Number of bots on the server.", nil, float64(info.Bots))
+	m.gauge("a2s_password_protected", "Whether the server requires a password.", nil, boolValue(info.Visibility == a2s.VisibilityPrivate))
+	m.gauge("a2s_vac_secured", "Whether the server is secured by VAC.", nil, boolValue(info.VAC == a2s.VACSecured))
+	m.gauge("a2s_ping_seconds", "Round-trip time of the A2S_INFO exchange.", nil, client.SRTT().Seconds())
+	e.observeLatency(info, client.SRTT())
+
+	if len(e.rules) > 0 {
+		e.probeRules(m, client)
+	}
+
+	m.gauge("a2s_probe_duration_seconds", "How long the probe took.", nil, time.Since(start).Seconds())
+	return m.err
+}
+
+// probeRules writes the whitelisted rules. Servers may disable A2S_RULES, which
+// is reported through a2s_rules_up rather than failing the probe.
+func (e *Exporter) probeRules(m *metricWriter, client *a2s.Client) {
+	rules, err := client.GetRules()
+	if err != nil {
+		m.gauge("a2s_rules_up", "Whether the server answered A2S_RULES.", nil, 0)
+		return
+	}
+	m.gauge("a2s_rules_up", "Whether the server answered A2S_RULES.", nil, 1)
+
+	for _, name := range e.rules {
+		value, err := rules.GetFloat(name)
+		if err != nil {
+			continue
+		}
+		m.gauge("a2s_rule", "Numeric value of a server rule (cvar).", []label{{"name", name}}, value)
+	}
+}
+
+
+// labelPolicy limits the cardinality of a label, see WithoutLabels,
+// WithLabelLimit and WithHashedLabel.
+type labelPolicy struct {
+	drop    bool
+	limit   int
+	buckets int
+	seen    map[string]struct{}
+}
+
+func (e *Exporter) policy(name string) *labelPolicy {
+	if e.labels == nil {
+		e.labels = make(map[string]*labelPolicy)
+	}
+	p := e.labels[name]
+	if p == nil {
+		p = &labelPolicy{}
+		e.labels[name] = p
+	}
+	return p
+}
+
+// applyPolicies drops or rewrites the labels with a policy.
+func (e *Exporter) applyPolicies(labels []label) []label {
+	if len(e.labels) == 0 {
+		return labels
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	result := labels[:0]
+	for _, l := range labels {
+		p := e.labels[l.name]
+		switch {
+		case p == nil:
+		case p.drop:
+			continue
+		case p.buckets > 0:
+			h := fnv.New32a()
+			io.WriteString(h, l.value)
+			l.value = fmt.Sprintf("bucket-%d", h.Sum32()%uint32(p.buckets))
+		case p.limit > 0:
+			if _, ok := p.seen[l.value]; !ok {
+				if len(p.seen) >= p.limit {
+					l.value = "other"
+				} else {
+					if p.seen == nil {
+						p.seen = make(map[string]struct{})
+					}
+					p.seen[l.value] = struct{}{}
+				}
+			}
+		}
+		result = append(result, l)
+	}
+	return result
+}
+
+
+type label struct {
+	name  string
+	value string
+}
+
+// metricWriter writes metrics in the Prometheus text format. The HELP and TYPE
+// lines are written once per metric name; samples of the same metric must be
+// written one after another. The first write error is kept in err.
+type metricWriter struct {
+	w    io.Writer
+	last string
+	err  error
+}
+
+func (m *metricWriter) gauge(name, help string, labels []label, value float64) {
+	m.header(name, help, "gauge")
+	m.sample(name, labels, value)
+}
+
+// header writes the HELP and TYPE lines, unless the previous sample belonged
+// to the same metric.
+func (m *metricWriter) header(name, help, kind string) {
+	if m.err != nil || name == m.last {
+		return
+	}
+	m.last = name
+	_, m.err = fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (m *metricWriter) sample(name string, labels []label, value float64) {
+	if m.err != nil {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=\"%s\"", l.name, escapeLabel(l.value))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	b.WriteByte('\n')
+
+	_, m.err = io.WriteString(m.w, b.String())
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
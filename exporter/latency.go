@@ -0,0 +1,136 @@
+//go:build !tinygo && !a2s_minimal
+
+package exporter
+
+import (
+	"cmp"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histogram.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+type latencyKey struct {
+	appID uint16
+	game  string
+}
+
+// histogram is a cumulative Prometheus histogram over latencyBuckets.
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.buckets == nil {
+		h.buckets = make([]uint64, len(latencyBuckets))
+	}
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+
+// observeLatency records the A2S_INFO round-trip time of a probed server in
+// the histogram of its game. The game label is subject to the same policies
+// as the one of a2s_info.
+func (e *Exporter) observeLatency(info *a2s.ServerInfo, rtt time.Duration) {
+	key := latencyKey{appID: info.AppID}
+	if labels := e.applyPolicies([]label{{"game", info.Game}}); len(labels) > 0 {
+		key.game = labels[0].value
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.latency == nil {
+		e.latency = make(map[latencyKey]*histogram)
+	}
+	h := e.latency[key]
+	if h == nil {
+		h = &histogram{}
+		e.latency[key] = h
+	}
+	h.observe(rtt.Seconds())
+}
+
+// MetricsHandler returns a handler for the exporter's own metrics, usually
+// mounted at /metrics. Unlike the probe endpoint, it aggregates over every
+// probe so far: a2s_query_duration_seconds is a histogram of the A2S_INFO
+// round-trip times per AppID and game, to compare the responsiveness of
+// engines across a fleet. a2s_probes_total and, with WithCircuitBreaker, the
+// a2s_circuit_breaker gauges tell problems of the exporter apart from
+// problems of the servers.
+func (e *Exporter) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		e.WriteMetrics(w)
+	})
+}
+
+// WriteMetrics writes the exporter's own metrics to w in the Prometheus text
+// format. See MetricsHandler.
+func (e *Exporter) WriteMetrics(w io.Writer) error {
+	e.mu.Lock()
+	succeeded, failed := e.succeeded, e.failed
+	keys := make([]latencyKey, 0, len(e.latency))
+	histograms := make(map[latencyKey]histogram, len(e.latency))
+	for key, h := range e.latency {
+		keys = append(keys, key)
+		histograms[key] = histogram{buckets: slices.Clone(h.buckets), count: h.count, sum: h.sum}
+	}
+	e.mu.Unlock()
+
+	slices.SortFunc(keys, func(a, b latencyKey) int {
+		return cmp.Or(cmp.Compare(a.appID, b.appID), cmp.Compare(a.game, b.game))
+	})
+
+	const name = "a2s_query_duration_seconds"
+	m := &metricWriter{w: w}
+	m.header(name, "Round-trip time of A2S_INFO exchanges, per game.", "histogram")
+	for _, key := range keys {
+		h := histograms[key]
+		labels := []label{{"app_id", strconv.Itoa(int(key.appID))}, {"game", key.game}}
+		for i, bound := range latencyBuckets {
+			m.sample(name+"_bucket", append(labels, label{"le", strconv.FormatFloat(bound, 'g', -1, 64)}), float64(h.buckets[i]))
+		}
+		m.sample(name+"_bucket", append(labels, label{"le", "+Inf"}), float64(h.count))
+		m.sample(name+"_sum", labels, h.sum)
+		m.sample(name+"_count", labels, float64(h.count))
+	}
+
+	m.header("a2s_probes_total", "Probes so far, by whether the server answered.", "counter")
+	m.sample("a2s_probes_total", []label{{"result", "success"}}, float64(succeeded))
+	m.sample("a2s_probes_total", []label{{"result", "failure"}}, float64(failed))
+
+	if e.breaker != nil {
+		stats := e.breaker.Stats()
+		m.gauge("a2s_circuit_breaker_failing", "Servers with unanswered requests.", nil, float64(stats.Failing))
+		m.gauge("a2s_circuit_breaker_open", "Servers whose probes are rejected by the circuit breaker.", nil, float64(stats.Open))
+	}
+	return m.err
+}
+
+// countProbe counts a probe for a2s_probes_total.
+func (e *Exporter) countProbe(answered bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if answered {
+		e.succeeded++
+	} else {
+		e.failed++
+	}
+}
@@ -0,0 +1,141 @@
+//go:build !js
+
+package a2s
+
+import (
+	"net"
+	"time"
+)
+
+
+// DefaultHappyEyeballsDelay is the delay between attempts recommended by
+// RFC 8305, for WithHappyEyeballs.
+const DefaultHappyEyeballsDelay = 250 * time.Millisecond
+
+
+// raceCandidates sends the packet to the connected address and, each after
+// the Happy Eyeballs delay or as soon as the previous attempt failed, to the
+// alternative addresses. The first connection that answers becomes the
+// connection of the client and the others are closed. If none answers, the
+// error of the last attempt is returned and the next exchange races again.
+func (c *Client) raceCandidates(packet, buffer []byte, deadline time.Time) (int, error) {
+	type reply struct {
+		conn net.Conn
+		data []byte
+		err  error
+	}
+	replies := make(chan reply, 1+len(c.candidates))
+	conns := make([]net.Conn, 0, 1+len(c.candidates))
+	pending := 0
+	send := func(conn net.Conn) error {
+		c.logPacket(logSent, packet)
+		if _, err := conn.Write(packet); err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+		c.race(conn)
+		pending++
+		go func() {
+			data := make([]byte, len(buffer))
+			n, err := conn.Read(data)
+			replies <- reply{conn, data[:n], err}
+		}()
+		return nil
+	}
+
+	var lastErr error
+	if err := send(c.conn); err != nil {
+		lastErr = err
+	}
+	next := 0
+	stagger := time.NewTimer(c.eyeballsDelay)
+	defer stagger.Stop()
+	if pending == 0 {
+		stagger.Reset(0)
+	}
+
+	for pending > 0 || next < len(c.candidates) {
+		var staggered <-chan time.Time
+		if next < len(c.candidates) {
+			staggered = stagger.C
+		}
+
+		select {
+		case r := <-replies:
+			pending--
+			if r.err == nil {
+				c.settle(r.conn, conns)
+				return copy(buffer, r.data), nil
+			}
+			lastErr = r.err
+			if pending == 0 {
+				stagger.Reset(0)
+			}
+
+		case <-staggered:
+			candidate := c.candidates[next]
+			next++
+			conn, err := c.dialUDP(candidate)
+			if err == nil {
+				conn.SetDeadline(deadline)
+				if err = send(conn); err != nil {
+					conn.Close()
+				}
+			}
+			if err != nil {
+				lastErr = err
+			}
+			if pending == 0 {
+				stagger.Reset(0)
+			} else {
+				stagger.Reset(c.eyeballsDelay)
+			}
+		}
+	}
+
+	c.connMu.Lock()
+	c.racing = nil
+	c.connMu.Unlock()
+	for _, conn := range conns {
+		if conn != c.conn {
+			conn.Close()
+		}
+	}
+	return 0, lastErr
+}
+
+// race registers a connection of the race, so interrupt wakes its read too.
+// If the context was canceled before, the read is cut short right away.
+func (c *Client) race(conn net.Conn) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	c.racing = append(c.racing, conn)
+	if c.ctx != nil && c.ctx.Err() != nil {
+		conn.SetReadDeadline(time.Now())
+	}
+}
+
+// settle makes the winning connection of a race the connection of the client
+// and closes the others, unless Close was called meanwhile.
+func (c *Client) settle(winner net.Conn, conns []net.Conn) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	c.racing = nil
+	for _, conn := range conns {
+		if conn != winner && conn != c.conn {
+			conn.Close()
+		}
+	}
+	c.candidates = nil
+	if winner == c.conn {
+		return
+	}
+	if c.closed.Load() {
+		winner.Close()
+		return
+	}
+	c.conn.Close()
+	c.conn = winner
+}
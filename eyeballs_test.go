@@ -0,0 +1,100 @@
+//go:build !js
+
+package a2s
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+
+// TestHappyEyeballsCancel cancels a query from another goroutine right after
+// a Happy Eyeballs race switched the connection of the client, so the
+// cancellation reads the connection the race just wrote. Run it with -race.
+func TestHappyEyeballsCancel(t *testing.T) {
+	silent := listenLoopback(t)
+	answering := listenLoopback(t)
+	response, err := EncodeInfo(&ServerInfo{Name: "eyeballs", Map: "de_dust2", Folder: "cstrike", Game: "Counter-Strike"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buffer := make([]byte, 1400)
+		for {
+			_, from, err := answering.ReadFromUDPAddrPort(buffer)
+			if err != nil {
+				return
+			}
+			answering.WriteToUDPAddrPort(response, from)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := NewClient(time.Second, WithHappyEyeballs(5*time.Millisecond), WithLogger(stallOnReceive{}))
+	if err := client.ConnectAddrPort(addrPortOf(silent)); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.candidates = []netip.AddrPort{addrPortOf(answering)}
+
+	// The race settles after about 5ms, and the query stalls for 100ms
+	// after that.
+	time.AfterFunc(50*time.Millisecond, cancel)
+	info, err := client.GetInfoContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "eyeballs" {
+		t.Errorf("got info of %q, want the answering candidate", info.Name)
+	}
+	if got, want := client.conn.RemoteAddr().String(), addrPortOf(answering).String(); got != want {
+		t.Errorf("connected to %s after the race, want %s", got, want)
+	}
+}
+
+// stallOnReceive is a log handler that stalls the query when its response
+// is logged, which happens after the race settled but before the query ends.
+type stallOnReceive struct{}
+
+func (stallOnReceive) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (stallOnReceive) Handle(_ context.Context, r slog.Record) error {
+	r.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == "direction" && attr.Value.String() == logReceived {
+			time.Sleep(100 * time.Millisecond)
+			return false
+		}
+		return true
+	})
+	return nil
+}
+
+func (h stallOnReceive) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h stallOnReceive) WithGroup(string) slog.Handler {
+	return h
+}
+
+func listenLoopback(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func addrPortOf(conn *net.UDPConn) netip.AddrPort {
+	return conn.LocalAddr().(*net.UDPAddr).AddrPort()
+}
+
@@ -0,0 +1,95 @@
+//go:build !js
+
+package a2s
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+
+// DialFunc opens a datagram connection to a server, see WithDialer.
+type DialFunc func(ctx context.Context, addr netip.AddrPort) (net.Conn, error)
+
+
+// dialUDP opens the socket of the client with the DialFunc of WithDialer, or
+// bound to the interface of WithInterface and the local address of
+// WithLocalAddr or picked from the pool of WithSourcePool and tuned by
+// WithSocketOptions, if there are any, or relayed by the proxy of WithSOCKS5.
+func (c *Client) dialUDP(addr netip.AddrPort) (net.Conn, error) {
+	if c.dialer != nil {
+		ctx := context.Background()
+		if c.ctx != nil {
+			ctx = c.ctx
+		}
+		return c.dialer(ctx, addr)
+	}
+	if c.socks != nil {
+		return c.dialSOCKS5(addr)
+	}
+	if c.iface == "" && c.sources == nil && c.localAddr == (netip.AddrPort{}) && c.socketOpts.isZero() {
+		return net.DialUDP("udp", nil, net.UDPAddrFromAddrPort(addr))
+	}
+
+	var (
+		dialer net.Dialer
+		local  netip.Addr
+	)
+	if c.iface != "" {
+		ifi, err := net.InterfaceByName(c.iface)
+		if err != nil {
+			return nil, fmt.Errorf("interface %s: %w", c.iface, err)
+		}
+		ip := addr.Addr()
+		if ip.Is6() && !ip.Is4In6() && ip.Zone() == "" && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()) {
+			// Link-local addresses are only meaningful within the scope of
+			// an interface, which is the one we were told to use.
+			addr = netip.AddrPortFrom(ip.WithZone(ifi.Name), addr.Port())
+		}
+		if local, err = interfaceAddr(ifi, ip.Unmap().Is4()); err != nil {
+			return nil, err
+		}
+		dialer.Control = bindToDevice(ifi.Name)
+	}
+	dialer.Control = chainControl(dialer.Control, c.socketOpts.control())
+	if c.sources != nil {
+		var err error
+		if local, err = c.sources.pick(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	if ip := c.localAddr.Addr(); ip.IsValid() && !ip.IsUnspecified() {
+		local = ip
+	}
+
+	dialer.LocalAddr = net.UDPAddrFromAddrPort(netip.AddrPortFrom(local, c.localAddr.Port()))
+	return dialer.Dial("udp", addr.String())
+}
+
+// interfaceAddr returns the first unicast address of the interface in the
+// given family, which the socket is bound to so the kernel routes its
+// packets through the interface.
+func interfaceAddr(ifi *net.Interface, ipv4 bool) (netip.Addr, error) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	for _, a := range addrs {
+		prefix, err := netip.ParsePrefix(a.String())
+		if err != nil {
+			continue
+		}
+		ip := prefix.Addr().Unmap()
+		if ip.Is4() == ipv4 && !ip.IsLinkLocalUnicast() {
+			return ip, nil
+		}
+	}
+	family := "IPv6"
+	if ipv4 {
+		family = "IPv4"
+	}
+	return netip.Addr{}, fmt.Errorf("interface %s has no %s address", ifi.Name, family)
+}
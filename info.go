@@ -0,0 +1,53 @@
+package a2s
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// HasKeyword reports whether the server lists the given tag in its keywords
+// (sv_tags), e.g. "secure" or "increased_maxplayers". Tags are compared
+// case-insensitively.
+func (info *ServerInfo) HasKeyword(name string) bool {
+	for _, keyword := range info.Keywords {
+		if strings.EqualFold(keyword, name) {
+			return true
+		}
+	}
+	return false
+}
+
+
+// ReportedAddressMismatch reports whether the address a GoldSource server
+// reports about itself differs from the address it was queried at, which
+// usually means it sits behind NAT or a redirect. A reported unspecified IP
+// (0.0.0.0) only has its port compared. If the server did not report a
+// parsable address, there is nothing to compare and it returns false.
+func (info *ServerInfo) ReportedAddressMismatch(queried netip.AddrPort) bool {
+	reported, err := netip.ParseAddrPort(info.ReportedAddress)
+	if err != nil {
+		return false
+	}
+
+	if reported.Port() != queried.Port() {
+		return true
+	}
+	if reported.Addr().IsUnspecified() {
+		return false
+	}
+	return reported.Addr().Unmap() != queried.Addr().Unmap()
+}
+
+
+// splitKeywords splits the comma-separated keywords string of an A2S_INFO
+// response, dropping surrounding whitespace and empty entries.
+func splitKeywords(tags string) []string {
+	keywords := make([]string, 0, strings.Count(tags, ",")+1)
+	for _, keyword := range strings.Split(tags, ",") {
+		keyword = strings.TrimSpace(keyword)
+		if keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
@@ -0,0 +1,70 @@
+package a2s
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+
+// ServerKey identifies a server across restarts, and across address changes
+// if it has a persistent SteamID, for keying the history and state of
+// monitored servers. Keys of different kinds never collide:
+//
+//	steam:85568397215007954   the SteamID of a server with a persistent
+//	                          game server account
+//	fp:9f86d081884c7d65       a fingerprint of the address and the info
+//	                          that rarely changes
+//	addr:203.0.113.10:27015   the address, if nothing else is known
+type ServerKey string
+
+// steamAccountAnonGameServer is the account type of anonymous game server
+// logins, whose SteamID changes with every restart.
+const steamAccountAnonGameServer = 4
+
+// NewServerKey derives the key of the server at addr from its info, which
+// may be nil. The SteamID is used if the server logged in with a persistent
+// account. Otherwise the key is a fingerprint of the address with the app,
+// game folder, game port and player limit, which survives restarts as long
+// as the operator keeps the configuration, but not the name, which operators
+// change to advertise events. The address keeps hosted servers that run the
+// default configuration on the same port apart. If addr is not valid, the
+// anonymous SteamID takes its place, which only lasts until the next
+// restart.
+//
+// The fingerprint is only used if the server reported its game port, and
+// the key falls back to addr otherwise. The key is empty if neither addr nor
+// a SteamID is known.
+func NewServerKey(info *ServerInfo, addr netip.AddrPort) ServerKey {
+	if info != nil && info.SteamID != 0 && info.SteamID>>52&0xF != steamAccountAnonGameServer {
+		return ServerKey("steam:" + strconv.FormatUint(info.SteamID, 10))
+	}
+
+	var identity string
+	switch {
+	case addr.IsValid():
+		identity = netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port()).String()
+	case info != nil && info.SteamID != 0:
+		identity = strconv.FormatUint(info.SteamID, 10)
+	default:
+		return ""
+	}
+	if info == nil || info.GamePort == 0 || info.AppID == 0 && info.Folder == "" {
+		if !addr.IsValid() {
+			return ""
+		}
+		return ServerKey("addr:" + identity)
+	}
+
+	fields := []string{
+		identity,
+		strconv.Itoa(int(info.AppID)),
+		info.Folder,
+		strconv.Itoa(int(info.GamePort)),
+		strconv.Itoa(int(info.MaxPlayers)),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x00")))
+	return ServerKey("fp:" + hex.EncodeToString(sum[:8]))
+}
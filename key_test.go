@@ -0,0 +1,57 @@
+package a2s
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+
+func TestNewServerKey(t *testing.T) {
+	addr := netip.MustParseAddrPort("203.0.113.10:27015")
+	info := &ServerInfo{Name: "Friday Night", AppID: 730, Folder: "csgo", GamePort: 27015, MaxPlayers: 24}
+
+	key := NewServerKey(info, addr)
+	if !strings.HasPrefix(string(key), "fp:") {
+		t.Fatalf("got key %s, want a fingerprint", key)
+	}
+	renamed := *info
+	renamed.Name = "Saturday Night"
+	if got := NewServerKey(&renamed, addr); got != key {
+		t.Errorf("renamed server has key %s, want %s", got, key)
+	}
+
+	noPort := *info
+	noPort.GamePort = 0
+	if got, want := NewServerKey(&noPort, addr), ServerKey("addr:203.0.113.10:27015"); got != want {
+		t.Errorf("server without game port has key %s, want %s", got, want)
+	}
+	if got := NewServerKey(&noPort, netip.AddrPort{}); got != "" {
+		t.Errorf("server without game port or address has key %s, want none", got)
+	}
+
+	persistent := *info
+	persistent.SteamID = 85568397215007954
+	if got, want := NewServerKey(&persistent, addr), ServerKey("steam:85568397215007954"); got != want {
+		t.Errorf("got key %s, want %s", got, want)
+	}
+}
+
+// TestNewServerKeyCollision keys two hosted servers with the default
+// configuration on the same port, which only their addresses tell apart.
+func TestNewServerKeyCollision(t *testing.T) {
+	info := &ServerInfo{Name: "Counter-Strike 2", AppID: 730, Folder: "csgo", GamePort: 27015, MaxPlayers: 10}
+	a := NewServerKey(info, netip.MustParseAddrPort("203.0.113.10:27015"))
+	b := NewServerKey(info, netip.MustParseAddrPort("198.51.100.7:27015"))
+	if a == b {
+		t.Errorf("servers on different hosts share the key %s", a)
+	}
+
+	anonymous := *info
+	anonymous.SteamID = 90071996842377216
+	other := anonymous
+	other.SteamID++
+	if a, b := NewServerKey(&anonymous, netip.AddrPort{}), NewServerKey(&other, netip.AddrPort{}); a == "" || a == b {
+		t.Errorf("servers without address got keys %q and %q, want distinct ones", a, b)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !js && !a2s_debug
+
+package a2s
+
+
+// trackLeak is a no-op unless built with the a2s_debug tag, see leak_debug.go.
+func (c *Client) trackLeak() {}
@@ -0,0 +1,26 @@
+//go:build !js && a2s_debug
+
+package a2s
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+
+// trackLeak warns on stderr if the client is garbage collected while still
+// connected, which means its socket, or its address in a Multiplexer, was
+// never released with Close. The warning includes the stack that connected
+// the client. Only builds with the a2s_debug tag track clients, as the stack
+// capture is too costly for production.
+func (c *Client) trackLeak() {
+	stack := debug.Stack()
+	runtime.SetFinalizer(c, nil)
+	runtime.SetFinalizer(c, func(c *Client) {
+		if c.IsConnected() {
+			fmt.Fprintf(os.Stderr, "a2s: Client for %s was never closed, connected at:\n%s", c.conn.RemoteAddr(), stack)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+//go:build !js && !tinygo && !a2s_minimal
+
+package a2s_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"runtime"
+	"testing"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+	"github.com/notedevil/valve-a2s/a2stest"
+)
+
+
+var leakInfo = &a2s.ServerInfo{Name: "leak", Map: "de_dust2", Folder: "cstrike", Game: "Counter-Strike", AppID: 240}
+
+// checkGoroutines fails the test if, once it and its other cleanups are
+// done, more goroutines run than when it was called. Goroutines get a second
+// to exit, since closing a connection does not wait for its readers.
+func checkGoroutines(t *testing.T) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > before {
+			if time.Now().After(deadline) {
+				stack := make([]byte, 1<<16)
+				stack = stack[:runtime.Stack(stack, true)]
+				t.Errorf("%d goroutines leaked:\n%s", runtime.NumGoroutine()-before, stack)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+
+func TestClientLifecycleLeaks(t *testing.T) {
+	checkGoroutines(t)
+	server := &a2stest.Server{Info: leakInfo}
+
+	for range 20 {
+		client, err := server.NewClient(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.GetInfoContext(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		client.Close()
+	}
+}
+
+// TestClientCloseInFlight closes a client while a query waits for a server
+// that never answers, which must end the query and everything it started.
+func TestClientCloseInFlight(t *testing.T) {
+	checkGoroutines(t)
+	server := &a2stest.Server{}
+	client, err := server.NewClient(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := client.GetInfo()
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	client.Close()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("query of a closed client succeeded")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("query still running after Close")
+	}
+}
+
+// TestClientContextCancelInFlight cancels the context of a query waiting for
+// a server that never answers.
+func TestClientContextCancelInFlight(t *testing.T) {
+	checkGoroutines(t)
+	server := &a2stest.Server{}
+	client, err := server.NewClient(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.GetInfoContext(ctx); err == nil {
+		t.Error("query with a cancelled context succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("query ended %v after its context", elapsed)
+	}
+}
+
+
+func TestMultiplexerLifecycleLeaks(t *testing.T) {
+	checkGoroutines(t)
+	addr := serveUDP(t)
+
+	mux, err := a2s.NewMultiplexer(4, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 20 {
+		client, err := mux.ClientAddrPort(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.GetInfo(); err != nil {
+			t.Fatal(err)
+		}
+		client.Close()
+	}
+	if err := mux.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMultiplexerCloseInFlight closes a Multiplexer while one of its clients
+// waits for a server that never answers.
+func TestMultiplexerCloseInFlight(t *testing.T) {
+	checkGoroutines(t)
+	silent, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { silent.Close() })
+
+	mux, err := a2s.NewMultiplexer(1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := mux.ClientAddrPort(silent.LocalAddr().(*net.UDPAddr).AddrPort())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	done := make(chan error)
+	go func() {
+		_, err := client.GetInfo()
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	mux.Close()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("query through a closed Multiplexer succeeded")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("query still running after the Multiplexer was closed")
+	}
+}
+
+// serveUDP answers every datagram on a loopback socket with leakInfo until
+// the test ends, and returns the address of the socket.
+func serveUDP(t *testing.T) netip.AddrPort {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := a2s.EncodeInfo(leakInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buffer := make([]byte, 1400)
+		for {
+			_, from, err := conn.ReadFromUDPAddrPort(buffer)
+			if err != nil {
+				return
+			}
+			conn.WriteToUDPAddrPort(response, from)
+		}
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+		<-done
+	})
+	return conn.LocalAddr().(*net.UDPAddr).AddrPort()
+}
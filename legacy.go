@@ -0,0 +1,67 @@
+//go:build !js
+
+package a2s
+
+import (
+	"encoding/binary"
+)
+
+
+// Text queries understood by pre-Steam HLDS versions and some server
+// emulators. Their replies use the same formats as the GoldSource A2S
+// responses: S2A_INFO_GOLD, S2A_PLAYER and S2A_RULES.
+const (
+	legacyDetailsQuery = "details"
+	legacyPlayersQuery = "players"
+	legacyRulesQuery   = "rules"
+)
+
+
+// getLegacyInfo sends the "details" text query after A2S_INFO went
+// unanswered by a GoldSource server, or any server with WithLegacyQueries. If
+// that fails as well, the original error is returned.
+func (c *Client) getLegacyInfo(infoErr error) (*ServerInfo, error) {
+	response, err := c.sendLegacyQuery(legacyDetailsQuery, S2A_INFO_GOLD)
+	if err != nil {
+		return nil, infoErr
+	}
+
+	info, err := c.decoder.parseGoldSourceInfo(response)
+	if err != nil {
+		return nil, err
+	}
+	c.goldSource = true
+	c.legacyQueries = true
+	return info, nil
+}
+
+// requestWithLegacyFallback sends a challenge-protected request. Servers that
+// only answered the "details" text query get the text query right away. For
+// other GoldSource servers the text query is tried when the A2S request fails,
+// and the A2S error is returned if that fails too.
+func (c *Client) requestWithLegacyFallback(packetType byte, query string, expectResponse byte) ([]byte, error) {
+	if c.legacyQueries {
+		return c.sendLegacyQuery(query, expectResponse)
+	}
+
+	response, err := c.requestWithChallenge(packetType, expectResponse)
+	if err == nil || !c.goldSource {
+		return response, err
+	}
+
+	if legacy, legacyErr := c.sendLegacyQuery(query, expectResponse); legacyErr == nil {
+		return legacy, nil
+	}
+	return nil, err
+}
+
+// sendLegacyQuery sends a null-terminated text query after the usual
+// 0xFFFFFFFF header.
+func (c *Client) sendLegacyQuery(query string, expectResponse byte) ([]byte, error) {
+	packet := make([]byte, 0, 4+len(query)+1)
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(Header))
+	packet = append(packet, query...)
+	packet = append(packet, 0)
+
+	return c.exchange(packet, expectResponse)
+}
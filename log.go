@@ -0,0 +1,84 @@
+//go:build !js
+
+package a2s
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+)
+
+
+// Directions of logged packets.
+const (
+	logSent     = "sent"
+	logReceived = "received"
+)
+
+var requestNames = map[byte]string{
+	A2S_INFO:                     "A2S_INFO",
+	A2S_PLAYER:                   "A2S_PLAYER",
+	A2S_RULES:                    "A2S_RULES",
+	A2S_SERVERQUERY_GETCHALLENGE: "A2S_SERVERQUERY_GETCHALLENGE",
+	A2A_PING:                     "A2A_PING",
+}
+
+var responseNames = map[byte]string{
+	S2C_CHALLENGE: "S2C_CHALLENGE",
+	S2A_INFO_SRC:  "S2A_INFO_SRC",
+	S2A_INFO_GOLD: "S2A_INFO_GOLD",
+	S2A_PLAYER:    "S2A_PLAYER",
+	S2A_RULES:     "S2A_RULES",
+	A2A_ACK:       "A2A_ACK",
+}
+
+
+// logPacket logs a sent or received datagram at debug level, with a hex dump
+// of its contents. It does nothing without WithLogger or if the handler does
+// not log debug records.
+func (c *Client) logPacket(direction string, data []byte) {
+	if c.logger == nil || !c.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("direction", direction),
+		slog.Int("size", len(data)),
+		slog.String("opcode", packetOpcode(direction, data)),
+	}
+	if c.conn != nil {
+		attrs = append(attrs, slog.String("server", c.conn.RemoteAddr().String()))
+	}
+	attrs = append(attrs, slog.String("dump", hex.Dump(data)))
+	c.logger.LogAttrs(context.Background(), slog.LevelDebug, "a2s packet", attrs...)
+}
+
+// packetOpcode decodes the type of a datagram for logging.
+func packetOpcode(direction string, data []byte) string {
+	if len(data) < 5 {
+		return "short"
+	}
+
+	switch binary.LittleEndian.Uint32(data) {
+	case uint32(SPLIT_FLAG):
+		return "split fragment"
+	case uint32(Header):
+	default:
+		return "unknown header"
+	}
+
+	names := responseNames
+	if direction == logSent {
+		names = requestNames
+	}
+	if name, ok := names[data[4]]; ok {
+		return name
+	}
+	if direction == logSent && bytes.IndexByte(data[4:], 0) == len(data)-5 {
+		return fmt.Sprintf("text query %q", data[4:len(data)-1])
+	}
+	return fmt.Sprintf("0x%02X", data[4])
+}
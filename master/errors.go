@@ -0,0 +1,16 @@
+//go:build !a2s_private
+
+package master
+
+import (
+	"errors"
+)
+
+var (
+	ErrNotConnected     = errors.New("not connected to master server")
+	ErrTimeout          = errors.New("request timeout")
+	ErrInvalidResponse  = errors.New("invalid response")
+	ErrShortResponse    = errors.New("response too short")
+	ErrInvalidFilter    = errors.New("invalid master server filter")
+	ErrNoProgress       = errors.New("master server returned no new servers")
+)
@@ -0,0 +1,251 @@
+//go:build !a2s_private
+
+package master
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+
+// Filter builds the backslash-delimited filter string understood by the
+// master server. Conditions are ANDed together; Nand and Nor add negated
+// groups. Methods return the receiver so calls can be chained:
+//
+//	filter := master.NewFilter().AppID(730).Map("de_dust2").NotEmpty()
+type Filter struct {
+	parts []filterPart
+}
+
+type filterPart struct {
+	key   string
+	value string
+	group *Filter
+}
+
+func NewFilter() *Filter {
+	return &Filter{}
+}
+
+
+// GameDir matches servers running the given mod directory, e.g. "cstrike".
+func (f *Filter) GameDir(dir string) *Filter {
+	return f.add("gamedir", dir)
+}
+
+// Map matches servers running the given map.
+func (f *Filter) Map(name string) *Filter {
+	return f.add("map", name)
+}
+
+// AppID matches servers running the given app.
+func (f *Filter) AppID(id uint32) *Filter {
+	return f.add("appid", strconv.FormatUint(uint64(id), 10))
+}
+
+// NotAppID matches servers that are not running the given app.
+func (f *Filter) NotAppID(id uint32) *Filter {
+	return f.add("napp", strconv.FormatUint(uint64(id), 10))
+}
+
+// NotFull matches servers that are not full.
+func (f *Filter) NotFull() *Filter {
+	return f.add("full", "1")
+}
+
+// NotEmpty matches servers that have at least one player.
+func (f *Filter) NotEmpty() *Filter {
+	return f.add("empty", "1")
+}
+
+// Empty matches servers that have no players.
+func (f *Filter) Empty() *Filter {
+	return f.add("noplayers", "1")
+}
+
+// Secure matches servers using anti-cheat technology (VAC, but potentially
+// others as well).
+func (f *Filter) Secure() *Filter {
+	return f.add("secure", "1")
+}
+
+// Dedicated matches dedicated servers.
+func (f *Filter) Dedicated() *Filter {
+	return f.add("dedicated", "1")
+}
+
+// Linux matches servers running on a Linux platform.
+func (f *Filter) Linux() *Filter {
+	return f.add("linux", "1")
+}
+
+// NoPassword matches servers that are not password protected.
+func (f *Filter) NoPassword() *Filter {
+	return f.add("password", "0")
+}
+
+// Whitelisted matches servers that are whitelisted.
+func (f *Filter) Whitelisted() *Filter {
+	return f.add("white", "1")
+}
+
+// NameMatch matches servers whose hostname matches the pattern. The pattern
+// can use * as a wildcard.
+func (f *Filter) NameMatch(pattern string) *Filter {
+	return f.add("name_match", pattern)
+}
+
+// VersionMatch matches servers whose version matches the pattern. The pattern
+// can use * as a wildcard.
+func (f *Filter) VersionMatch(pattern string) *Filter {
+	return f.add("version_match", pattern)
+}
+
+// GameType matches servers with all of the given tags in sv_tags.
+func (f *Filter) GameType(tags ...string) *Filter {
+	return f.add("gametype", strings.Join(tags, ","))
+}
+
+// GameAddr matches servers on the given IP address, with an optional port.
+func (f *Filter) GameAddr(addr string) *Filter {
+	return f.add("gameaddr", addr)
+}
+
+// Condition adds a condition given as text, e.g. on the command line, with
+// the key as it appears in the filter string. Build rejects keys that none
+// of the methods above add, so Nand and Nor groups cannot be given this way.
+func (f *Filter) Condition(key, value string) *Filter {
+	return f.add(key, value)
+}
+
+// Nand excludes servers that match all conditions of the group.
+func (f *Filter) Nand(group *Filter) *Filter {
+	f.parts = append(f.parts, filterPart{key: "nand", group: group})
+	return f
+}
+
+// Nor excludes servers that match any condition of the group.
+func (f *Filter) Nor(group *Filter) *Filter {
+	f.parts = append(f.parts, filterPart{key: "nor", group: group})
+	return f
+}
+
+
+// Build validates the filter and serializes it. It returns an error wrapping
+// ErrInvalidFilter if a key is unknown, a value contains a backslash or NUL
+// byte, a condition is given twice, mutually exclusive conditions are combined, or a Nand/Nor
+// group is empty.
+func (f *Filter) Build() (string, error) {
+	if err := f.validate(true); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	f.write(&sb)
+	return sb.String(), nil
+}
+
+// String returns the serialized filter without validating it.
+func (f *Filter) String() string {
+	var sb strings.Builder
+	f.write(&sb)
+	return sb.String()
+}
+
+
+// filterKeys are the keys of the conditions a Filter can hold.
+var filterKeys = map[string]bool{
+	"gamedir": true, "map": true, "appid": true, "napp": true,
+	"full": true, "empty": true, "noplayers": true, "secure": true,
+	"dedicated": true, "linux": true, "password": true, "white": true,
+	"name_match": true, "version_match": true, "gametype": true, "gameaddr": true,
+}
+
+func (f *Filter) add(key, value string) *Filter {
+	f.parts = append(f.parts, filterPart{key: key, value: value})
+	return f
+}
+
+// conditions returns the number of conditions a group counts as when it is
+// nested in a Nand or Nor. Nested groups count their own header plus their
+// conditions.
+func (f *Filter) conditions() int {
+	n := 0
+	for _, part := range f.parts {
+		n++
+		if part.group != nil {
+			n += part.group.conditions()
+		}
+	}
+	return n
+}
+
+func (f *Filter) write(sb *strings.Builder) {
+	for _, part := range f.parts {
+		sb.WriteByte('\\')
+		sb.WriteString(part.key)
+		sb.WriteByte('\\')
+		if part.group != nil {
+			sb.WriteString(strconv.Itoa(part.group.conditions()))
+			part.group.write(sb)
+			continue
+		}
+		sb.WriteString(part.value)
+	}
+}
+
+// validate checks the filter. Repeated and mutually exclusive conditions are
+// only rejected at the top level, where they are ANDed; inside a group they
+// are how alternatives are listed.
+func (f *Filter) validate(top bool) error {
+	seen := make(map[string]string)
+
+	for _, part := range f.parts {
+		if part.group != nil {
+			if len(part.group.parts) == 0 {
+				return fmt.Errorf("%w: empty %s group", ErrInvalidFilter, part.key)
+			}
+			if err := part.group.validate(false); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !filterKeys[part.key] {
+			return fmt.Errorf("%w: unknown key %q", ErrInvalidFilter, part.key)
+		}
+		if strings.ContainsAny(part.value, "\\\x00") {
+			return fmt.Errorf("%w: %s value %q contains a reserved character", ErrInvalidFilter, part.key, part.value)
+		}
+		if _, ok := seen[part.key]; ok && top {
+			return fmt.Errorf("%w: %s given more than once", ErrInvalidFilter, part.key)
+		}
+		seen[part.key] = part.value
+	}
+
+	if !top {
+		return nil
+	}
+
+	if _, ok := seen["empty"]; ok {
+		if _, ok := seen["noplayers"]; ok {
+			return fmt.Errorf("%w: empty and noplayers are mutually exclusive", ErrInvalidFilter)
+		}
+	}
+	if appID, ok := seen["appid"]; ok && seen["napp"] == appID {
+		return fmt.Errorf("%w: appid and napp exclude the same app %s", ErrInvalidFilter, appID)
+	}
+
+	return nil
+}
+
+
+// QueryFilter is like Query but takes a Filter, validating it first.
+func (m *Client) QueryFilter(region Region, filter *Filter) ([]string, error) {
+	s, err := filter.Build()
+	if err != nil {
+		return nil, err
+	}
+	return m.Query(region, s)
+}
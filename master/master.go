@@ -0,0 +1,336 @@
+//go:build !a2s_private
+
+// Package master implements the Valve master server query protocol (MSQ),
+// used to discover the addresses of game servers to query with package a2s.
+//
+// This package is experimental: its API may change between minor releases.
+// It is not available when building with the a2s_private tag.
+package master
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net"
+	"net/netip"
+	"time"
+)
+
+
+const (
+	DefaultAddr = "hl2master.steampowered.com:27011"
+	MSQ_QUERY        = 0x31
+	M2A_SERVER_BATCH = 0x66
+)
+
+// masterSeed is the address that starts a master server query and, when
+// returned by the server, marks the end of the list.
+var masterSeed = netip.AddrPortFrom(netip.IPv4Unspecified(), 0)
+
+
+// Defaults for the master server request pacing and resume behaviour. Valve's
+// master servers drop clients that page through results too quickly.
+const (
+	DefaultPacing  = time.Second
+	DefaultRetries = 3
+)
+
+
+// Client queries a Valve master server for the addresses of game
+// servers matching a region and filter.
+type Client struct {
+	conn    net.Conn
+	timeout time.Duration
+	pacing  time.Duration
+	retries int
+
+	lastQuery time.Time
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithPacing sets the minimum interval between two requests sent to the
+// master server. Zero disables pacing.
+func WithPacing(interval time.Duration) Option {
+	return func(m *Client) {
+		m.pacing = interval
+	}
+}
+
+// WithRetries sets how many times a scan resumes from the last returned
+// address after a timeout before giving up.
+func WithRetries(retries int) Option {
+	return func(m *Client) {
+		m.retries = retries
+	}
+}
+
+func NewClient(timeout time.Duration, opts ...Option) *Client {
+	m := &Client{
+		timeout: timeout,
+		pacing:  DefaultPacing,
+		retries: DefaultRetries,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+
+// Connect dials the master server at the given address. An empty address
+// connects to DefaultAddr.
+func (m *Client) Connect(addr string) error {
+	if addr == "" {
+		addr = DefaultAddr
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return err
+	}
+
+	m.conn = conn
+	return nil
+}
+
+var _ io.Closer = (*Client)(nil)
+
+// Close closes the connection to the master server. Closing a closed client
+// does nothing.
+func (m *Client) Close() error {
+	if m.conn != nil {
+		err := m.conn.Close()
+		m.conn = nil
+		return err
+	}
+	return nil
+}
+
+
+// Region selects the part of the world the master server returns servers
+// for.
+type Region byte
+
+const (
+	RegionUSEast       Region = 0x00
+	RegionUSWest       Region = 0x01
+	RegionSouthAmerica Region = 0x02
+	RegionEurope       Region = 0x03
+	RegionAsia         Region = 0x04
+	RegionAustralia    Region = 0x05
+	RegionMiddleEast   Region = 0x06
+	RegionAfrica       Region = 0x07
+	RegionWorld        Region = 0xFF
+)
+
+
+// QueryAddrPorts asks the master server for all servers in the given region
+// matching the backslash-delimited filter string, e.g. `\appid\730\empty\1`,
+// and collects them into a slice. If an error occurs mid-scan, the addresses
+// collected so far are returned with it.
+func (m *Client) QueryAddrPorts(region Region, filter string) ([]netip.AddrPort, error) {
+	var servers []netip.AddrPort
+	err := m.Each(region, filter, func(addr netip.AddrPort) bool {
+		servers = append(servers, addr)
+		return true
+	})
+	return servers, err
+}
+
+// Query is like QueryAddrPorts but returns the addresses in "ip:port" form,
+// ready to be passed to a2s.Client.Connect.
+func (m *Client) Query(region Region, filter string) ([]string, error) {
+	var servers []string
+	err := m.Each(region, filter, func(addr netip.AddrPort) bool {
+		servers = append(servers, addr.String())
+		return true
+	})
+	return servers, err
+}
+
+// Each calls fn for every server in the given region matching the filter, in
+// the order the master server returns them. Paging is handled transparently:
+// the query is re-sent seeded with the last address of each batch until the
+// server returns the 0.0.0.0:0 terminator. Returning false from fn stops the
+// scan early without an error.
+func (m *Client) Each(region Region, filter string, fn func(addr netip.AddrPort) bool) error {
+	return m.Resume(NewCheckpoint(region, filter), fn)
+}
+
+// Resume continues the scan recorded in the checkpoint, calling fn for every
+// server after the checkpoint's seed address. The checkpoint is updated after
+// each address is handed to fn, so it can be saved at any point (including
+// after an error or after fn returned false) and passed to Resume again later
+// to pick up where the scan stopped. Requests are paced according to
+// WithPacing, and a timed-out request is re-sent from the same seed up to
+// the number of times set by WithRetries. If the server replies with a batch
+// that brings no new address and no terminator, Resume returns ErrNoProgress
+// rather than requesting the same batch again.
+func (m *Client) Resume(cp *Checkpoint, fn func(addr netip.AddrPort) bool) error {
+	if m.conn == nil {
+		return ErrNotConnected
+	}
+	if cp.Done {
+		return nil
+	}
+
+	attempts := 0
+
+	for {
+		batch, err := m.queryBatch(cp.Region, cp.Seed, cp.Filter)
+		if err != nil {
+			if errors.Is(err, ErrTimeout) && attempts < m.retries {
+				attempts++
+				continue
+			}
+			return err
+		}
+		attempts = 0
+
+		if len(batch) == 0 {
+			cp.Done = true
+			return nil
+		}
+
+		seed := cp.Seed
+		for _, addr := range batch {
+			if addr == masterSeed {
+				cp.Done = true
+				return nil
+			}
+			if addr == cp.Seed {
+				continue
+			}
+			cp.Seed = addr
+			cp.Count++
+			if !fn(addr) {
+				return nil
+			}
+		}
+
+		// A batch without a new address would be requested again from the
+		// same seed forever.
+		if cp.Seed == seed {
+			return ErrNoProgress
+		}
+	}
+}
+
+// Checkpoint records how far a master server scan has progressed. It is
+// plain data and can be stored, e.g. as JSON, to continue a long scan later
+// with Client.Resume.
+type Checkpoint struct {
+	Region Region         `json:"region"`
+	Filter string         `json:"filter"`
+	Seed   netip.AddrPort `json:"seed"`
+	Count  int            `json:"count"`
+	Done   bool           `json:"done"`
+}
+
+// NewCheckpoint returns a checkpoint for a scan that has not started yet.
+func NewCheckpoint(region Region, filter string) *Checkpoint {
+	return &Checkpoint{
+		Region: region,
+		Filter: filter,
+		Seed:   masterSeed,
+	}
+}
+
+
+// Servers returns an iterator over the servers in the given region matching
+// the filter, for use with range:
+//
+//	for addr, err := range client.Servers(master.RegionEurope, `\appid\730`) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// If the scan fails, the iterator yields a single zero address with the error
+// and stops.
+func (m *Client) Servers(region Region, filter string) iter.Seq2[netip.AddrPort, error] {
+	return func(yield func(netip.AddrPort, error) bool) {
+		stopped := false
+		err := m.Each(region, filter, func(addr netip.AddrPort) bool {
+			if !yield(addr, nil) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if err != nil && !stopped {
+			yield(netip.AddrPort{}, err)
+		}
+	}
+}
+
+
+// queryBatch sends a single MSQ request starting after seed and returns the
+// addresses contained in the reply.
+func (m *Client) queryBatch(region Region, seed netip.AddrPort, filter string) ([]netip.AddrPort, error) {
+	packet := make([]byte, 0, 2+21+1+len(filter)+1)
+	packet = append(packet, MSQ_QUERY, byte(region))
+	packet = seed.AppendTo(packet)
+	packet = append(packet, 0)
+	packet = append(packet, filter...)
+	packet = append(packet, 0)
+
+	if m.pacing > 0 && !m.lastQuery.IsZero() {
+		if wait := m.pacing - time.Since(m.lastQuery); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	m.lastQuery = time.Now()
+
+	m.conn.SetDeadline(time.Now().Add(m.timeout))
+
+	if _, err := m.conn.Write(packet); err != nil {
+		return nil, fmt.Errorf("write error: %w", err)
+	}
+
+	buffer := make([]byte, 4096)
+	n, err := m.conn.Read(buffer)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return nil, ErrTimeout
+		}
+		return nil, fmt.Errorf("read error: %w", err)
+	}
+
+	return parseMasterResponse(buffer[:n])
+}
+
+// parseMasterResponse parses a M2A_SERVER_BATCH reply. The reply starts with
+// the 0xFFFFFFFF header, the 0x66 type byte and a 0x0A byte, followed by
+// 6-byte entries of an IPv4 address and a big-endian port.
+func parseMasterResponse(data []byte) ([]netip.AddrPort, error) {
+	prefix := []byte{0xFF, 0xFF, 0xFF, 0xFF, M2A_SERVER_BATCH, 0x0A}
+	if len(data) < len(prefix) {
+		return nil, ErrShortResponse
+	}
+	if !bytes.Equal(data[:len(prefix)], prefix) {
+		return nil, ErrInvalidResponse
+	}
+
+	data = data[len(prefix):]
+	servers := make([]netip.AddrPort, 0, len(data)/6)
+
+	for offset := 0; offset+6 <= len(data); offset += 6 {
+		ip := netip.AddrFrom4([4]byte(data[offset : offset+4]))
+		port := binary.BigEndian.Uint16(data[offset+4:])
+		servers = append(servers, netip.AddrPortFrom(ip, port))
+	}
+
+	return servers, nil
+}
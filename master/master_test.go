@@ -0,0 +1,76 @@
+//go:build !a2s_private
+
+package master
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+
+// TestResumeNoProgress answers every request with a batch holding only the
+// seed it was sent, without the terminator, which must end the scan instead
+// of requesting the same batch forever.
+func TestResumeNoProgress(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	seed := netip.MustParseAddrPort("192.0.2.1:27015")
+	reply := []byte{0xFF, 0xFF, 0xFF, 0xFF, M2A_SERVER_BATCH, 0x0A}
+	reply = append(reply, 192, 0, 2, 1, 0x69, 0x87)
+	go func() {
+		buffer := make([]byte, 1400)
+		for {
+			_, from, err := server.ReadFromUDPAddrPort(buffer)
+			if err != nil {
+				return
+			}
+			server.WriteToUDPAddrPort(reply, from)
+		}
+	}()
+
+	client := NewClient(time.Second)
+	if err := client.Connect(server.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	cp := NewCheckpoint(RegionWorld, "")
+	cp.Seed = seed
+	err = client.Resume(cp, func(netip.AddrPort) bool {
+		t.Error("fn called without a new address")
+		return true
+	})
+	if !errors.Is(err, ErrNoProgress) {
+		t.Fatalf("Resume returned %v, want ErrNoProgress", err)
+	}
+	if cp.Done || cp.Seed != seed {
+		t.Errorf("checkpoint moved to %+v", cp)
+	}
+}
+
+func TestFilterCondition(t *testing.T) {
+	query, err := NewFilter().AppID(730).Condition("map", "de_dust2").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `\appid\730\map\de_dust2`; query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+
+	for _, filter := range []*Filter{
+		NewFilter().Condition("mapp", "de_dust2"),
+		NewFilter().Condition("nand", "1"),
+		NewFilter().Condition("map", `de_dust2\appid\440`),
+	} {
+		if _, err := filter.Build(); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%s: got %v, want ErrInvalidFilter", filter, err)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Package mobile is a small facade over package a2s that only uses types
+// gomobile can bind, so Android and iOS apps can query servers directly:
+//
+//	gomobile bind -target=android github.com/notedevil/valve-a2s/mobile
+//
+// Structs are flat, durations are plain milliseconds, and lists are exposed
+// through Len/Get accessors since gomobile cannot bind slices of structs.
+package mobile
+
+import (
+	"strings"
+	"time"
+
+	a2s "github.com/notedevil/valve-a2s"
+)
+
+
+// Info is the server info returned by Client.Info.
+type Info struct {
+	Name       string
+	Map        string
+	Folder     string
+	Game       string
+	AppID      int
+	Players    int
+	MaxPlayers int
+	Bots       int
+	// ServerType is "dedicated", "listen" or "sourcetv".
+	ServerType string
+	// Environment is "linux", "windows" or "mac".
+	Environment string
+	Password    bool
+	VAC         bool
+	Version     string
+	// Keywords is the comma-separated list of server tags.
+	Keywords string
+}
+
+// Player is a single entry of a PlayerList.
+type Player struct {
+	Name            string
+	Score           int
+	DurationSeconds float64
+}
+
+// PlayerList is the list of players returned by Client.Players.
+type PlayerList struct {
+	players []Player
+}
+
+func (l *PlayerList) Len() int {
+	return len(l.players)
+}
+
+// Get returns the player at index i, or nil if i is out of range.
+func (l *PlayerList) Get(i int) *Player {
+	if i < 0 || i >= len(l.players) {
+		return nil
+	}
+	return &l.players[i]
+}
+
+// Rule is a single entry of a RuleList.
+type Rule struct {
+	Name  string
+	Value string
+}
+
+// RuleList is the list of rules returned by Client.Rules.
+type RuleList struct {
+	rules a2s.Rules
+}
+
+func (l *RuleList) Len() int {
+	return len(l.rules)
+}
+
+// Get returns the rule at index i, or nil if i is out of range.
+func (l *RuleList) Get(i int) *Rule {
+	if i < 0 || i >= len(l.rules) {
+		return nil
+	}
+	return &Rule{Name: l.rules[i].Name, Value: l.rules[i].Value}
+}
+
+// Value returns the value of the named rule, matched case-insensitively, or an
+// empty string if the server did not send it.
+func (l *RuleList) Value(name string) string {
+	value, _ := l.rules.Get(name)
+	return value
+}
+
+
+// Client queries a single server.
+type Client struct {
+	client *a2s.Client
+}
+
+// NewClient returns a client using the given timeout in milliseconds.
+func NewClient(timeoutMillis int64) *Client {
+	return &Client{
+		client: a2s.NewClient(time.Duration(timeoutMillis) * time.Millisecond),
+	}
+}
+
+// Connect connects to the server at the given "host:port" address.
+func (c *Client) Connect(addr string) error {
+	return c.client.Connect(addr)
+}
+
+func (c *Client) Close() error {
+	return c.client.Close()
+}
+
+func (c *Client) Info() (*Info, error) {
+	info, err := c.client.GetInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Info{
+		Name:        info.Name,
+		Map:         info.Map,
+		Folder:      info.Folder,
+		Game:        info.Game,
+		AppID:       int(info.AppID),
+		Players:     int(info.Players),
+		MaxPlayers:  int(info.MaxPlayers),
+		Bots:        int(info.Bots),
+		ServerType:  info.ServerType.String(),
+		Environment: info.Environment.String(),
+		Password:    info.Visibility == a2s.VisibilityPrivate,
+		VAC:         info.VAC == a2s.VACSecured,
+		Version:     info.Version,
+		Keywords:    strings.Join(info.Keywords, ","),
+	}, nil
+}
+
+func (c *Client) Players() (*PlayerList, error) {
+	players, err := c.client.GetPlayers()
+	if err != nil {
+		return nil, err
+	}
+
+	list := &PlayerList{players: make([]Player, 0, len(players))}
+	for _, player := range players {
+		list.players = append(list.players, Player{
+			Name:            player.Name,
+			Score:           int(player.Score),
+			DurationSeconds: float64(player.Duration),
+		})
+	}
+	return list, nil
+}
+
+func (c *Client) Rules() (*RuleList, error) {
+	rules, err := c.client.GetRules()
+	if err != nil {
+		return nil, err
+	}
+	return &RuleList{rules: rules}, nil
+}
+
+// Ping returns the round-trip time to the server in milliseconds.
+func (c *Client) Ping() (int64, error) {
+	rtt, err := c.client.RTT()
+	if err != nil {
+		return 0, err
+	}
+	return rtt.Milliseconds(), nil
+}
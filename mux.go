@@ -0,0 +1,500 @@
+//go:build !js && !tinygo && !a2s_minimal
+
+package a2s
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+
+// Multiplexer sends queries to many servers from a small pool of UDP sockets
+// and routes every reply back to the Client registered for its remote address.
+// It is meant for mass scanning, where one dialed socket per server would
+// quickly exhaust file descriptors.
+type Multiplexer struct {
+	timeout time.Duration
+	sockets []*net.UDPConn
+	// sources holds the source addresses of WithMultiplexerSources, and
+	// bySource the sockets bound to each of them.
+	sources  *SourcePool
+	bySource map[netip.Addr][]*net.UDPConn
+	// listen opens the sockets, tuned by WithMultiplexerSocketOptions.
+	listen net.ListenConfig
+
+	mu      sync.Mutex
+	targets map[netip.AddrPort]*muxConn
+	next    int
+	closed  bool
+
+	wg sync.WaitGroup
+
+	// Replies dropped because of an unknown sender or a full queue.
+	unknown    atomic.Uint64
+	overflowed atomic.Uint64
+
+	// Limits of the traffic of all clients, see WithGlobalRate and
+	// WithMaxInFlight.
+	rateMu    sync.Mutex
+	rate      *bucket
+	rateLimit float64
+	rateBurst float64
+	inflight  chan struct{}
+}
+
+// MultiplexerOption configures a Multiplexer.
+type MultiplexerOption func(*Multiplexer)
+
+// WithGlobalRate caps the aggregate rate of requests sent by all clients of
+// the multiplexer at rate per second, with bursts of up to burst requests,
+// so mass scans neither saturate the uplink nor get the scanning address
+// flagged. It complements a per-server RateLimiter.
+func WithGlobalRate(rate float64, burst int) MultiplexerOption {
+	return func(m *Multiplexer) {
+		m.rateLimit = rate
+		m.rateBurst = float64(max(burst, 1))
+		m.rate = &bucket{tokens: m.rateBurst, last: time.Now()}
+	}
+}
+
+// WithMaxInFlight caps the number of requests of all clients that are
+// awaiting a reply at once. A request counts until its client has received a
+// reply, timed out or been closed.
+func WithMaxInFlight(n int) MultiplexerOption {
+	return func(m *Multiplexer) {
+		m.inflight = make(chan struct{}, max(n, 1))
+	}
+}
+
+// WithMultiplexerSources sends the queries of the clients from the addresses
+// of the pool. The multiplexer opens its number of sockets for each address,
+// and the pool picks the address, and so the sockets, of every client.
+func WithMultiplexerSources(pool *SourcePool) MultiplexerOption {
+	return func(m *Multiplexer) {
+		m.sources = pool
+	}
+}
+
+// WithMultiplexerSocketOptions tunes the sockets of the multiplexer, e.g. to
+// lower the TTL of scans or mark them with a DSCP class.
+func WithMultiplexerSocketOptions(opts SocketOptions) MultiplexerOption {
+	return func(m *Multiplexer) {
+		m.listen.Control = opts.control()
+	}
+}
+
+// NewMultiplexer opens the given number of unconnected UDP sockets and starts
+// a reader for each of them. Clients handed out by the multiplexer use the
+// given timeout.
+func NewMultiplexer(sockets int, timeout time.Duration, opts ...MultiplexerOption) (*Multiplexer, error) {
+	if sockets < 1 {
+		sockets = 1
+	}
+
+	m := &Multiplexer{
+		timeout: timeout,
+		targets: make(map[netip.AddrPort]*muxConn),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.sources == nil {
+		for i := 0; i < sockets; i++ {
+			conn, err := m.listenUDP(netip.AddrPort{})
+			if err != nil {
+				m.Close()
+				return nil, err
+			}
+			m.sockets = append(m.sockets, conn)
+		}
+	} else {
+		m.bySource = make(map[netip.Addr][]*net.UDPConn)
+		for _, source := range m.sources.addrs() {
+			for i := 0; i < sockets; i++ {
+				conn, err := m.listenUDP(netip.AddrPortFrom(source, 0))
+				if err != nil {
+					m.Close()
+					return nil, err
+				}
+				m.sockets = append(m.sockets, conn)
+				m.bySource[source] = append(m.bySource[source], conn)
+			}
+		}
+	}
+
+	for _, conn := range m.sockets {
+		m.wg.Add(1)
+		go m.readLoop(conn)
+	}
+
+	return m, nil
+}
+
+// listenUDP opens an unconnected socket bound to addr, or to any address if
+// addr is invalid.
+func (m *Multiplexer) listenUDP(addr netip.AddrPort) (*net.UDPConn, error) {
+	local := ""
+	if addr.IsValid() {
+		local = addr.String()
+	}
+	conn, err := m.listen.ListenPacket(context.Background(), "udp", local)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+
+// Client returns a connected Client for the given address whose packets are
+// sent through one of the shared sockets. It resolves the address and calls
+// ClientAddrPort.
+func (m *Multiplexer) Client(addr string, opts ...Option) (*Client, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return m.ClientAddrPort(udpAddr.AddrPort(), opts...)
+}
+
+// ClientAddrPort returns a connected Client for the given address whose
+// packets are sent through one of the shared sockets. The Client keeps its own
+// challenge and retry state, just like a dialed one. Closing it releases the
+// address so it can be registered again. Only one Client may be registered per
+// address. The options configure the Client as in NewClient.
+func (m *Multiplexer) ClientAddrPort(addr netip.AddrPort, opts ...Option) (*Client, error) {
+	key := unmapAddrPort(addr)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrMultiplexerClosed
+	}
+	if _, ok := m.targets[key]; ok {
+		return nil, ErrTargetRegistered
+	}
+	sockets := m.sockets
+	if m.sources != nil {
+		source, err := m.sources.pick(key)
+		if err != nil {
+			return nil, err
+		}
+		sockets = m.bySource[source]
+	}
+
+	conn := &muxConn{
+		mux:      m,
+		socket:   sockets[m.next%len(sockets)],
+		remote:   key,
+		incoming: make(chan []byte, 8),
+		closed:   make(chan struct{}),
+	}
+	m.next++
+	m.targets[key] = conn
+
+	client := NewClient(m.timeout, opts...)
+	client.conn = conn
+	client.connected = true
+	client.trackLeak()
+	return client, nil
+}
+
+var _ io.Closer = (*Multiplexer)(nil)
+
+// Close closes all shared sockets and unblocks every registered Client.
+// Clients obtained from the multiplexer can no longer be used afterwards.
+// Closing a closed multiplexer does nothing.
+func (m *Multiplexer) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	targets := make([]*muxConn, 0, len(m.targets))
+	for _, conn := range m.targets {
+		targets = append(targets, conn)
+	}
+	m.mu.Unlock()
+
+	for _, conn := range targets {
+		conn.Close()
+	}
+
+	var err error
+	for _, socket := range m.sockets {
+		if cerr := socket.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	m.wg.Wait()
+	return err
+}
+
+
+// readLoop reads datagrams from a shared socket and hands them to the
+// registered target matching the sender address. Packets from unknown senders,
+// or for targets whose queue is full, are dropped.
+func (m *Multiplexer) readLoop(socket *net.UDPConn) {
+	defer m.wg.Done()
+
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := socket.ReadFromUDPAddrPort(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		m.mu.Lock()
+		target := m.targets[unmapAddrPort(addr)]
+		m.mu.Unlock()
+		if target == nil {
+			m.unknown.Add(1)
+			continue
+		}
+
+		packet := make([]byte, n)
+		copy(packet, buffer[:n])
+
+		select {
+		case target.incoming <- packet:
+		default:
+			m.overflowed.Add(1)
+		}
+	}
+}
+
+// MultiplexerStats are health counters of a Multiplexer, see Stats.
+type MultiplexerStats struct {
+	// Targets is the number of clients currently registered.
+	Targets int `json:"targets"`
+	// InFlight is the number of requests awaiting a reply, if
+	// WithMaxInFlight is set.
+	InFlight int `json:"in_flight"`
+	// DroppedUnknown counts datagrams from addresses without a registered
+	// client, e.g. late replies to clients that were already closed.
+	DroppedUnknown uint64 `json:"dropped_unknown"`
+	// DroppedOverflow counts replies dropped because the queue of their
+	// client was full.
+	DroppedOverflow uint64 `json:"dropped_overflow"`
+}
+
+// Stats returns the current health counters of the multiplexer, which tell
+// problems of the multiplexer apart from unresponsive servers.
+func (m *Multiplexer) Stats() MultiplexerStats {
+	m.mu.Lock()
+	targets := len(m.targets)
+	m.mu.Unlock()
+
+	return MultiplexerStats{
+		Targets:         targets,
+		InFlight:        len(m.inflight),
+		DroppedUnknown:  m.unknown.Load(),
+		DroppedOverflow: m.overflowed.Load(),
+	}
+}
+
+func (m *Multiplexer) unregister(key netip.AddrPort) {
+	m.mu.Lock()
+	delete(m.targets, key)
+	m.mu.Unlock()
+}
+
+
+// muxConn is the net.Conn handed to Clients created by a Multiplexer. Writes go
+// out through a shared socket, reads are served from the demultiplexed queue.
+type muxConn struct {
+	mux      *Multiplexer
+	socket   *net.UDPConn
+	remote   netip.AddrPort
+	incoming chan []byte
+
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	deadline time.Time
+	// deadlineChanged is closed when the deadline changes, to wake Read.
+	deadlineChanged chan struct{}
+	// holding is set while the conn holds a slot of WithMaxInFlight.
+	holding bool
+}
+
+func (c *muxConn) Read(b []byte) (int, error) {
+	defer c.release()
+
+	for {
+		c.mu.Lock()
+		deadline := c.deadline
+		if c.deadlineChanged == nil {
+			c.deadlineChanged = make(chan struct{})
+		}
+		changed := c.deadlineChanged
+		c.mu.Unlock()
+
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+
+		select {
+		case packet := <-c.incoming:
+			stopTimer(timer)
+			return copy(b, packet), nil
+		case <-timeout:
+			return 0, os.ErrDeadlineExceeded
+		case <-c.closed:
+			stopTimer(timer)
+			return 0, net.ErrClosed
+		case <-changed:
+			// Wait again for the new deadline, which may already have
+			// passed, as when a context is cancelled.
+			stopTimer(timer)
+		}
+	}
+}
+
+func (c *muxConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	if err := c.acquire(); err != nil {
+		return 0, err
+	}
+	n, err := c.socket.WriteToUDPAddrPort(b, c.remote)
+	if err != nil {
+		// No response will come to hand the slot back.
+		c.release()
+	}
+	return n, err
+}
+
+// acquire waits for the global rate limit and a slot of WithMaxInFlight
+// before a request is sent. The time spent waiting does not count against the
+// timeout of the client: the read deadline is pushed back by as much.
+func (c *muxConn) acquire() error {
+	m := c.mux
+	if m.rate == nil && m.inflight == nil {
+		return nil
+	}
+	start := time.Now()
+
+	if m.rate != nil {
+		m.rateMu.Lock()
+		delay := m.rate.take(start, m.rateLimit, m.rateBurst)
+		m.rateMu.Unlock()
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-c.closed:
+				return net.ErrClosed
+			}
+		}
+	}
+
+	c.mu.Lock()
+	holding := c.holding
+	c.mu.Unlock()
+	if m.inflight != nil && !holding {
+		select {
+		case m.inflight <- struct{}{}:
+		case <-c.closed:
+			return net.ErrClosed
+		}
+	}
+
+	c.mu.Lock()
+	c.holding = c.holding || m.inflight != nil
+	if !c.deadline.IsZero() {
+		c.deadline = c.deadline.Add(time.Since(start))
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// release gives back the slot of WithMaxInFlight, if the conn holds one.
+func (c *muxConn) release() {
+	c.mu.Lock()
+	holding := c.holding
+	c.holding = false
+	c.mu.Unlock()
+
+	if holding {
+		<-c.mux.inflight
+	}
+}
+
+func (c *muxConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.release()
+		c.mux.unregister(c.remote)
+	})
+	return nil
+}
+
+func (c *muxConn) LocalAddr() net.Addr {
+	return c.socket.LocalAddr()
+}
+
+func (c *muxConn) RemoteAddr() net.Addr {
+	return net.UDPAddrFromAddrPort(c.remote)
+}
+
+func (c *muxConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline also wakes a Read in progress, so it picks up the new
+// deadline like reads on a real socket do.
+func (c *muxConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	if c.deadlineChanged != nil {
+		close(c.deadlineChanged)
+		c.deadlineChanged = nil
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline is a no-op, writes on the shared socket never block long
+// enough to need one.
+func (c *muxConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// stopTimer stops the timer, if there is one.
+func stopTimer(timer *time.Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+
+// unmapAddrPort normalizes IPv4-mapped IPv6 addresses, as returned by a
+// dual-stack socket, to plain IPv4 so they compare equal to resolved targets.
+func unmapAddrPort(addr netip.AddrPort) netip.AddrPort {
+	return netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+}
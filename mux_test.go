@@ -0,0 +1,33 @@
+//go:build !js && !tinygo && !a2s_minimal
+
+package a2s
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+
+// TestMultiplexerWriteErrorReleasesSlot sends to a port no datagram can be
+// sent to, which must give back the WithMaxInFlight slot the request took.
+func TestMultiplexerWriteErrorReleasesSlot(t *testing.T) {
+	mux, err := NewMultiplexer(1, time.Second, WithMaxInFlight(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mux.Close()
+
+	client, err := mux.ClientAddrPort(netip.MustParseAddrPort("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := client.GetInfo(); err == nil {
+		t.Fatal("GetInfo to port 0 succeeded")
+	}
+	if n := len(mux.inflight); n != 0 {
+		t.Errorf("%d slots held after the write failed, want 0", n)
+	}
+}
@@ -0,0 +1,391 @@
+//go:build !js
+
+package a2s
+
+import (
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+
+// WithZeroCopyStrings makes the parsers return strings that share memory with
+// the received packet instead of copying every field. This saves one
+// allocation per string for consumers that parse millions of responses and
+// only inspect the fields transiently. Strings in the results are only
+// guaranteed to stay valid until the next query on the same client, so copy
+// any value (e.g. with strings.Clone) that needs to be retained.
+func WithZeroCopyStrings() Option {
+	return func(c *Client) {
+		c.decoder.ZeroCopyStrings = true
+	}
+}
+
+// WithSortedRules makes GetRules return the rules sorted by name. The sort is
+// stable, so diffs and hashes of consecutive polls are not perturbed by
+// servers that return their rules in varying order.
+func WithSortedRules() Option {
+	return func(c *Client) {
+		c.sortRules = true
+	}
+}
+
+// WithParseState makes the client reuse the buffers of the given state for
+// every query. See ParseState for how this affects returned results.
+func WithParseState(state *ParseState) Option {
+	return func(c *Client) {
+		c.decoder.State = state
+	}
+}
+
+// WithLegacyPing makes CheckFeatures probe the deprecated A2A_PING request.
+// Only enable it for old GoldSource servers or lab setups where the exchange
+// still works. Once the server has answered, RTT uses the lightweight ping.
+func WithLegacyPing() Option {
+	return func(c *Client) {
+		c.legacyPing = true
+	}
+}
+
+// WithLegacyQueries makes GetInfo try the pre-Steam "details" text query
+// when A2S_INFO times out, for old HLDS versions and server emulators that
+// only answer text queries. Without it, the text query is only tried for
+// servers that answered as GoldSource before, since it doubles the time a
+// dead server takes to time out.
+func WithLegacyQueries() Option {
+	return func(c *Client) {
+		c.legacyFallback = true
+	}
+}
+
+// WithLegacyNoChallenge stops the client from appending the cached challenge
+// to A2S_INFO requests. Some ancient or emulated servers reject A2S_INFO
+// packets with a trailing challenge, which the client otherwise sends once it
+// has obtained one, e.g. through GetPlayers. Challenge-protected requests are
+// not affected.
+func WithLegacyNoChallenge() Option {
+	return func(c *Client) {
+		c.noInfoChallenge = true
+	}
+}
+
+// WithoutChecksumVerification accepts compressed responses whose CRC32
+// checksum does not match instead of failing with ErrChecksumMismatch. The
+// mismatch is still reported in SplitInfo and ServerSnapshot.Checksum.
+func WithoutChecksumVerification() Option {
+	return func(c *Client) {
+		c.skipChecksum = true
+	}
+}
+
+// WithAdaptiveTimeout derives the timeout of each exchange from the smoothed
+// RTT of the server (see Client.SRTT) multiplied by factor, instead of always
+// waiting for the fixed timeout. The result is never shorter than minimum and
+// never longer than the timeout passed to NewClient, which is also used until
+// the first reply arrives. This lets scans give up on dead servers quickly
+// while still waiting long enough for distant ones.
+func WithAdaptiveTimeout(factor float64, minimum time.Duration) Option {
+	return func(c *Client) {
+		c.adaptiveFactor = factor
+		c.adaptiveMinimum = minimum
+	}
+}
+
+// WithTracer makes the client start spans for its operations, with the
+// server address, query kind, packet sizes and retry counts as attributes.
+// See Tracer for the spans and how to connect it to OpenTelemetry.
+func WithTracer(tracer Tracer) Option {
+	return func(c *Client) {
+		c.tracer = tracer
+	}
+}
+
+// WithLogger makes the client log through the given handler. At debug level,
+// every sent and received datagram is logged with its direction, size,
+// decoded opcode and a hex dump, which helps diagnosing unusual server
+// implementations.
+func WithLogger(handler slog.Handler) Option {
+	return func(c *Client) {
+		c.logger = slog.New(handler)
+	}
+}
+
+// WithDeadlineBudget bounds every public operation of the client, such as
+// QueryAll or ConnectContext, by the total of the budget and splits it across
+// the resolve, challenge and data phases, see DeadlineBudget. Each exchange
+// still waits no longer than the client's timeout. Once the budget of an
+// operation is exhausted, the remaining exchanges fail with ErrTimeout, so
+// QueryAll under a tight budget still returns the info it gathered, with the
+// players or rules reported as timed out, rather than overrunning it.
+func WithDeadlineBudget(budget DeadlineBudget) Option {
+	return func(c *Client) {
+		c.budget = budget
+	}
+}
+
+// WithRateLimiter makes the client wait for the limiter before sending each
+// request. Share one RateLimiter between all clients, including those of a
+// Multiplexer, to bound the total rate towards each server. A limiter with a
+// rate of zero or less lets every request through without waiting.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// WithCircuitBreaker makes the client report unanswered requests to the
+// breaker and fail queries with ErrCircuitOpen while the circuit of its
+// server is open. See CircuitBreaker.
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(c *Client) {
+		c.breaker = breaker
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy, e.g. to retry timeouts with
+// exponential backoff when scanning servers over the internet:
+//
+//	a2s.WithRetryPolicy(a2s.RetryPolicy{
+//		MaxAttempts: 4,
+//		Backoff:     200 * time.Millisecond,
+//		Multiplier:  2,
+//		Jitter:      0.2,
+//		Retry:       a2s.RetryTimeouts,
+//	})
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithClock makes the client keep time with clock instead of SystemClock, so
+// tests can advance a fake clock instead of waiting for backoffs, rate limits
+// and deadline budgets.
+func WithClock(clock Clock) Option {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
+
+// WithRandSource makes the jitter of the RetryPolicy come from src instead of
+// the global random source, so a seeded source reproduces the same waits
+// between retries in tests and simulations. The source is used by the client
+// only, so it need not be safe for concurrent use unless shared.
+func WithRandSource(src rand.Source) Option {
+	return func(c *Client) {
+		c.random = rand.New(src)
+	}
+}
+
+// WithChallengeCache makes the client reuse challenges of the cache and
+// store the ones it receives, to save the challenge round trip of
+// GetPlayers and GetRules across clients. Share one ChallengeCache between
+// all clients of a process.
+func WithChallengeCache(cache *ChallengeCache) Option {
+	return func(c *Client) {
+		c.challenges = cache
+	}
+}
+
+// WithInfoValidator registers a check that GetInfo runs on every parsed
+// response, e.g. that the map of your own servers starts with "ctf_". If it
+// returns an error, GetInfo returns it wrapped in ErrValidation instead of
+// the info. Checks run in the order they were registered.
+func WithInfoValidator(check func(*ServerInfo) error) Option {
+	return func(c *Client) {
+		c.checks.info = append(c.checks.info, check)
+	}
+}
+
+// WithPlayersValidator registers a check for GetPlayers, see
+// WithInfoValidator.
+func WithPlayersValidator(check func([]PlayerInfo) error) Option {
+	return func(c *Client) {
+		c.checks.players = append(c.checks.players, check)
+	}
+}
+
+// WithRulesValidator registers a check for GetRules, see WithInfoValidator.
+// It sees the rules after WithSortedRules sorted them.
+func WithRulesValidator(check func(Rules) error) Option {
+	return func(c *Client) {
+		c.checks.rules = append(c.checks.rules, check)
+	}
+}
+
+// WithInfoTransform registers a hook that GetInfo passes every info through
+// before returning it, to sanitize names, inject labels or convert units
+// once instead of at every call site. The hook may modify the info in place
+// or return a different one. Hooks run after the validators, in the order
+// they were registered. Clients of a Multiplexer take the same options, so
+// hooks apply to batch queries as well.
+func WithInfoTransform(hook func(*ServerInfo) *ServerInfo) Option {
+	return func(c *Client) {
+		c.hooks.info = append(c.hooks.info, hook)
+	}
+}
+
+// WithPlayersTransform registers a hook for GetPlayers, see
+// WithInfoTransform.
+func WithPlayersTransform(hook func([]PlayerInfo) []PlayerInfo) Option {
+	return func(c *Client) {
+		c.hooks.players = append(c.hooks.players, hook)
+	}
+}
+
+// WithRulesTransform registers a hook for GetRules, see WithInfoTransform.
+func WithRulesTransform(hook func(Rules) Rules) Option {
+	return func(c *Client) {
+		c.hooks.rules = append(c.hooks.rules, hook)
+	}
+}
+
+// WithSnapshotTransform registers a hook for QueryAll, run on the snapshot
+// after the hooks of the individual queries, e.g. to enrich it with data
+// from elsewhere.
+func WithSnapshotTransform(hook func(*ServerSnapshot) *ServerSnapshot) Option {
+	return func(c *Client) {
+		c.hooks.snapshot = append(c.hooks.snapshot, hook)
+	}
+}
+
+// WithInterface sends the queries of the client through the named network
+// interface, such as a WireGuard tunnel to a private network, regardless of
+// the routing table. The socket is bound to the first address of the
+// interface in the family of the server and, on Linux, to the interface
+// itself. IPv6 link-local servers without a zone are scoped to the
+// interface. It has no effect on clients of a Multiplexer, whose sockets are
+// shared. See the package documentation for a complete example.
+func WithInterface(name string) Option {
+	return func(c *Client) {
+		c.iface = name
+	}
+}
+
+// WithLocalAddr binds the socket of the client to the local address and
+// port, so multi-homed hosts control which address queries come from and
+// firewalls can match the port. An invalid or unspecified address such as
+// 0.0.0.0 only fixes the port, and a port of 0 lets the system pick one. The
+// address takes precedence over those of WithInterface and WithSourcePool,
+// and must be of the family of the server. A fixed port can only be bound by
+// one socket at a time, unless SocketOptions.ReusePort is set: otherwise it
+// is not suited to Happy Eyeballs, and re-dialing for WithReresolve fails
+// while the old socket is open. Like WithInterface, it has no effect on
+// clients of a Multiplexer.
+func WithLocalAddr(addr netip.AddrPort) Option {
+	return func(c *Client) {
+		c.localAddr = addr
+	}
+}
+
+// WithSocketOptions tunes the socket of the client, e.g. to mark queries with
+// a DSCP class on QoS-aware networks. Like WithInterface, it has no effect on
+// clients of a Multiplexer, see WithMultiplexerSocketOptions.
+func WithSocketOptions(opts SocketOptions) Option {
+	return func(c *Client) {
+		c.socketOpts = opts
+	}
+}
+
+// WithResolver resolves server host names with resolver instead of
+// net.DefaultResolver, e.g. to ask the DNS server of a VPN for names that only
+// exist inside it.
+func WithResolver(resolver *net.Resolver) Option {
+	return func(c *Client) {
+		c.resolver = resolver
+	}
+}
+
+// WithHappyEyeballs makes Connect keep an address of the other family when a
+// host name resolves to both IPv4 and IPv6 addresses. The first query is then
+// sent to the preferred IPv4 address and, after delay without an answer, to
+// the IPv6 one as well, like RFC 8305 describes for TCP. Whichever answers
+// first is used from then on, so a host with a broken address family is
+// still reached without waiting for a timeout on every query. A delay of
+// DefaultHappyEyeballsDelay is a good start.
+func WithHappyEyeballs(delay time.Duration) Option {
+	return func(c *Client) {
+		c.eyeballsDelay = delay
+	}
+}
+
+// WithSourcePool sends the queries of the client from an address of the
+// pool, picked by its policy when the client connects. Combined with
+// WithInterface, the address of the pool takes precedence over the address
+// of the interface. For clients of a Multiplexer see WithMultiplexerSources.
+func WithSourcePool(pool *SourcePool) Option {
+	return func(c *Client) {
+		c.sources = pool
+	}
+}
+
+// WithReresolve makes the client resolve the host name given to Connect
+// again before a query once the last resolution is older than interval, and
+// after every query that timed out. If the host no longer resolves to the
+// address the client is connected to, it re-dials the new address, so
+// servers on dynamic DNS are followed without reconnecting by hand. An
+// interval of 0 resolves before every query.
+func WithReresolve(interval time.Duration) Option {
+	return func(c *Client) {
+		c.reresolveInterval = max(interval, 0)
+	}
+}
+
+// WithSRV lets Connect take host names without a port, like "example.com".
+// The query endpoint is then looked up in the SRV records of the service,
+// _a2s._udp.example.com for DefaultSRVService, and the host itself is
+// queried on DefaultPort if there are none. Addresses with a port are used as
+// given. An empty service selects DefaultSRVService.
+func WithSRV(service string) Option {
+	if service == "" {
+		service = DefaultSRVService
+	}
+	return func(c *Client) {
+		c.srvService = service
+	}
+}
+
+// WithMetadata attaches arbitrary values to the client, such as the name of
+// the community or the region a server belongs to. They are returned by
+// Metadata and carried into every ServerSnapshot, so results can be matched
+// up without a lookup table keyed by address. Repeated options are merged.
+func WithMetadata(metadata map[string]any) Option {
+	return func(c *Client) {
+		if c.metadata == nil {
+			c.metadata = make(map[string]any, len(metadata))
+		}
+		maps.Copy(c.metadata, metadata)
+	}
+}
+
+// WithSOCKS5 relays the queries of the client through the SOCKS5 proxy at
+// addr with the UDP ASSOCIATE command, for networks that block outgoing UDP
+// or for measuring latency from where the proxy is. An empty username skips
+// authentication. The username and password can be at most 255 bytes long;
+// connecting fails with ErrProxyFailed otherwise. Each connected client
+// holds its own association, and the proxy must relay UDP, which many SSH
+// based proxies do not. The interface, local and source address and socket
+// options do not apply to proxied clients.
+func WithSOCKS5(addr, username, password string) Option {
+	return func(c *Client) {
+		c.socks = &socksProxy{addr: addr, username: username, password: password}
+	}
+}
+
+// WithDialer makes the client open its connection with dial instead of
+// net.DialUDP, to use sockets set up by the caller, a userspace network stack
+// such as a WireGuard netstack, or a test double. The connection must keep
+// datagram boundaries: every Write sends one request and every Read returns
+// one response. It takes precedence over WithInterface, WithLocalAddr,
+// WithSocketOptions, WithSourcePool and WithSOCKS5.
+func WithDialer(dial DialFunc) Option {
+	return func(c *Client) {
+		c.dialer = dial
+	}
+}
@@ -0,0 +1,443 @@
+package a2s
+
+import (
+	"encoding/binary"
+	"math"
+	"unsafe"
+)
+
+
+const (
+	Header        = 0xFFFFFFFF
+	SPLIT_FLAG    = 0xFFFFFFFE
+	A2S_INFO      = 0x54
+	A2S_PLAYER    = 0x55
+	A2S_RULES     = 0x56
+	A2S_CHALLENGE = 0x41
+	S2C_CHALLENGE = 0x41
+	S2A_INFO_SRC  = 0x49
+	S2A_INFO_GOLD = 0x6D
+	S2A_PLAYER    = 0x44
+	S2A_RULES     = 0x45
+	A2S_PING      = 0x69
+	S2A_PING      = 0x6A
+
+	A2S_SERVERQUERY_GETCHALLENGE = 0x57
+
+	// A2A_PING and A2A_ACK are the names Valve's documentation uses for the
+	// deprecated ping exchange.
+	A2A_PING = A2S_PING
+	A2A_ACK  = S2A_PING
+)
+
+// AppIDTheShip is the AppID of The Ship, whose servers send extra fields in
+// A2S_INFO and A2S_PLAYER responses.
+const AppIDTheShip = 2400
+
+
+// Decoder parses A2S response packets. It has no network dependency, so it
+// can be used on its own, e.g. in packet decoders compiled to WebAssembly.
+// Client uses a Decoder internally; the zero value is ready to use.
+type Decoder struct {
+	// ZeroCopyStrings makes decoded strings share memory with the packet
+	// instead of copying every field. See WithZeroCopyStrings.
+	ZeroCopyStrings bool
+
+	// State, if set, provides reusable buffers for decoded results. See
+	// ParseState.
+	State *ParseState
+
+	// AppID selects game specific fields, such as the extra player data of
+	// The Ship. DecodeInfo sets it from the decoded Source info.
+	AppID uint16
+}
+
+
+// DecodeInfo decodes an A2S_INFO response packet, including its 0xFFFFFFFF
+// header, from a Source or GoldSource server.
+func (d *Decoder) DecodeInfo(packet []byte) (*ServerInfo, error) {
+	responseType, payload, err := splitHeader(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	switch responseType {
+	case S2A_INFO_SRC:
+		info, err := d.parseSourceInfo(payload)
+		if err != nil {
+			return nil, err
+		}
+		d.AppID = info.AppID
+		return info, nil
+	case S2A_INFO_GOLD:
+		return d.parseGoldSourceInfo(payload)
+	default:
+		return nil, &ProtocolError{Expected: S2A_INFO_SRC, Actual: responseType}
+	}
+}
+
+// DecodePlayers decodes an A2S_PLAYER response packet, including its
+// 0xFFFFFFFF header.
+func (d *Decoder) DecodePlayers(packet []byte) ([]PlayerInfo, error) {
+	responseType, payload, err := splitHeader(packet)
+	if err != nil {
+		return nil, err
+	}
+	if responseType != S2A_PLAYER {
+		return nil, &ProtocolError{Expected: S2A_PLAYER, Actual: responseType}
+	}
+	return d.parsePlayersResponse(payload)
+}
+
+// DecodeRules decodes an A2S_RULES response packet, including its 0xFFFFFFFF
+// header.
+func (d *Decoder) DecodeRules(packet []byte) (Rules, error) {
+	responseType, payload, err := splitHeader(packet)
+	if err != nil {
+		return nil, err
+	}
+	if responseType != S2A_RULES {
+		return nil, &ProtocolError{Expected: S2A_RULES, Actual: responseType}
+	}
+	rules, err := d.parseRulesResponse(payload)
+	if err != nil {
+		return nil, err
+	}
+	return Rules(rules), nil
+}
+
+
+// splitHeader checks the 0xFFFFFFFF header of a single-packet response and
+// returns the response type and the payload following it.
+func splitHeader(packet []byte) (byte, []byte, error) {
+	if len(packet) < 5 {
+		return 0, nil, ErrShortResponse
+	}
+	if binary.LittleEndian.Uint32(packet) != Header {
+		return 0, nil, ErrInvalidResponse
+	}
+	return packet[4], packet[5:], nil
+}
+
+
+// parseSourceInfo parses the response to A2S_INFO request from Source (HL2) servers and returns a ServerInfo object.
+// It returns an error if the response is too short.
+
+func (d *Decoder) parseSourceInfo(data []byte) (*ServerInfo, error) {
+	// The protocol, four empty strings, the app ID, seven bytes and an empty
+	// version.
+	if len(data) < 15 {
+		return nil, ErrShortResponse
+	}
+
+	info := d.newServerInfo()
+	offset := 0
+
+	info.Protocol = data[offset]
+	offset++
+
+	info.Name = d.readString(data, &offset)
+	info.Map = d.readString(data, &offset)
+	info.Folder = d.readString(data, &offset)
+	info.Game = d.readString(data, &offset)
+
+	if offset+2 <= len(data) {
+		info.AppID = binary.LittleEndian.Uint16(data[offset:])
+		offset += 2
+	}
+
+	if offset+3 > len(data) {
+		return nil, ErrShortResponse
+	}
+	info.Players = data[offset]
+	offset++
+	info.MaxPlayers = data[offset]
+	offset++
+	info.Bots = data[offset]
+	offset++
+
+	if offset+4 > len(data) {
+		return nil, ErrShortResponse
+	}
+	info.ServerType = ServerType(data[offset])
+	offset++
+	info.Environment = Environment(data[offset])
+	offset++
+	info.Visibility = Visibility(data[offset])
+	offset++
+	info.VAC = VACStatus(data[offset])
+	offset++
+
+	if info.AppID == AppIDTheShip {
+		if offset+3 > len(data) {
+			return nil, ErrShortResponse
+		}
+		info.ShipMode = data[offset]
+		offset++
+		info.ShipWitnesses = data[offset]
+		offset++
+		info.ShipDuration = data[offset]
+		offset++
+	}
+
+	info.Version = d.readString(data, &offset)
+
+	if offset < len(data) {
+		info.EDF = data[offset]
+		offset++
+
+		if info.EDF&0x80 != 0 && offset+2 <= len(data) {
+			info.GamePort = binary.LittleEndian.Uint16(data[offset:])
+			offset += 2
+		}
+
+		if info.EDF&0x10 != 0 && offset+8 <= len(data) {
+			info.SteamID = binary.LittleEndian.Uint64(data[offset:])
+			offset += 8
+		}
+
+		if info.EDF&0x40 != 0 && offset+2 <= len(data) {
+			info.SourceTV.Port = binary.LittleEndian.Uint16(data[offset:])
+			offset += 2
+			info.SourceTV.Name = d.readString(data, &offset)
+		}
+
+		if info.EDF&0x20 != 0 {
+			tags := d.readString(data, &offset)
+			if tags != "" {
+				info.Keywords = splitKeywords(tags)
+			}
+		}
+
+		if info.EDF&0x01 != 0 && offset+8 <= len(data) {
+			info.GameID = binary.LittleEndian.Uint64(data[offset:])
+		}
+	}
+
+	return info, nil
+}
+
+// parseGoldSourceInfo parses the response to A2S_INFO request from GoldSource (HL1) servers and returns a ServerInfo object.
+// If the server runs a mod, the mod block is parsed into ServerInfo.Mod.
+// It returns an error if the response is too short.
+func (d *Decoder) parseGoldSourceInfo(data []byte) (*ServerInfo, error) {
+	info := d.newServerInfo()
+	offset := 0
+
+	info.ReportedAddress = d.readString(data, &offset)
+	info.Name = d.readString(data, &offset)
+	info.Map = d.readString(data, &offset)
+	info.Folder = d.readString(data, &offset)
+	info.Game = d.readString(data, &offset)
+
+	if offset+7 > len(data) {
+		return nil, ErrShortResponse
+	}
+	info.Players = data[offset]
+	offset++
+	info.MaxPlayers = data[offset]
+	offset++
+
+	info.Protocol = data[offset]
+	offset++
+	// GoldSource sends the codes in upper case; they are stored in the
+	// lower case of Source so they compare equal to the constants.
+	info.ServerType = ServerType(data[offset]).Normalize()
+	offset++
+	info.Environment = Environment(data[offset]).Normalize()
+	offset++
+	info.Visibility = Visibility(data[offset])
+	offset++
+
+	modFlag := data[offset]
+	offset++
+
+	if modFlag == 1 {
+		mod := &ModInfo{}
+		mod.Link = d.readString(data, &offset)
+		mod.DownloadLink = d.readString(data, &offset)
+
+		if offset+11 > len(data) {
+			return nil, ErrShortResponse
+		}
+		offset++
+		mod.Version = int32(binary.LittleEndian.Uint32(data[offset:]))
+		offset += 4
+		mod.Size = int32(binary.LittleEndian.Uint32(data[offset:]))
+		offset += 4
+		mod.Type = data[offset]
+		offset++
+		mod.DLL = data[offset]
+		offset++
+
+		info.Mod = mod
+	}
+
+	if offset >= len(data) {
+		return nil, ErrShortResponse
+	}
+	info.VAC = VACStatus(data[offset])
+	offset++
+
+	if offset < len(data) {
+		info.Bots = data[offset]
+	}
+
+	return info, nil
+}
+
+
+// parsePlayersResponse parses the response to A2S_PLAYER request and returns a slice of PlayerInfo.
+// The function returns an error if the response is too short.
+// The players are returned in the order they were received from the server.
+// For The Ship servers, the deaths and money blocks appended after the player list are
+// parsed as well, based on the decoder's AppID.
+func (d *Decoder) parsePlayersResponse(data []byte) ([]PlayerInfo, error) {
+	if len(data) < 1 {
+		return nil, ErrShortResponse
+	}
+
+	offset := 0
+	numPlayers := int(data[offset])
+	offset++
+
+	players := d.playerSlice(numPlayers)
+	
+	for i := 0; i < numPlayers && offset < len(data); i++ {
+		var player PlayerInfo
+		
+		player.Index = data[offset]
+		offset++
+		
+		player.Name = d.readString(data, &offset)
+		
+		if offset+4 > len(data) {
+			return nil, ErrShortResponse
+		}
+		player.Score = int32(binary.LittleEndian.Uint32(data[offset:]))
+		offset += 4
+		
+		if offset+4 > len(data) {
+			return nil, ErrShortResponse
+		}
+		player.Duration = math.Float32frombits(binary.LittleEndian.Uint32(data[offset:]))
+		offset += 4
+
+		players = append(players, player)
+	}
+
+	if d.AppID == AppIDTheShip {
+		for i := range players {
+			if offset+8 > len(data) {
+				return nil, ErrShortResponse
+			}
+			players[i].Deaths = int32(binary.LittleEndian.Uint32(data[offset:]))
+			offset += 4
+			players[i].Money = int32(binary.LittleEndian.Uint32(data[offset:]))
+			offset += 4
+		}
+	}
+
+	if d.State != nil {
+		d.State.players = players
+	}
+	return players, nil
+}
+
+// parseRulesResponse parses the response to A2S_RULES request and returns a slice of server rules.
+// Each rule is a key-value pair, where key and value are strings.
+// The function returns an error if the response is too short.
+// The rules are returned in the order they were received from the server.
+func (d *Decoder) parseRulesResponse(data []byte) ([]Rule, error) {
+	if len(data) < 2 {
+		return nil, ErrShortResponse
+	}
+
+	offset := 0
+	numRules := int(binary.LittleEndian.Uint16(data[offset:]))
+	offset += 2
+
+	rules := d.ruleSlice(numRules)
+	
+	for i := 0; i < numRules && offset < len(data); i++ {
+		var rule Rule
+		rule.Name = d.readString(data, &offset)
+		rule.Value = d.readString(data, &offset)
+		rules = append(rules, rule)
+	}
+
+	if d.State != nil {
+		d.State.rules = rules
+	}
+	return rules, nil
+}
+// readString reads a null-terminated string from the given byte slice, starting from the given offset. It returns the string and updates the offset to point after the null byte. If the offset points to the end of the slice, it returns an empty string.
+
+func readString(data []byte, offset *int) string {
+	if *offset >= len(data) {
+		return ""
+	}
+	
+	start := *offset
+	for *offset < len(data) && data[*offset] != 0 {
+		*offset++
+	}
+	
+	str := string(data[start:*offset])
+	if *offset < len(data) {
+		*offset++
+	}
+	return str
+}
+
+
+// readString reads a null-terminated string like the package-level readString.
+// When ZeroCopyStrings is set, the returned string aliases data instead of
+// copying it.
+func (d *Decoder) readString(data []byte, offset *int) string {
+	if !d.ZeroCopyStrings {
+		return readString(data, offset)
+	}
+
+	if *offset >= len(data) {
+		return ""
+	}
+
+	start := *offset
+	for *offset < len(data) && data[*offset] != 0 {
+		*offset++
+	}
+
+	var str string
+	if *offset > start {
+		str = unsafe.String(&data[start], *offset-start)
+	}
+	if *offset < len(data) {
+		*offset++
+	}
+	return str
+}
+
+func (d *Decoder) newServerInfo() *ServerInfo {
+	if d.State == nil {
+		return &ServerInfo{}
+	}
+	d.State.info = ServerInfo{}
+	return &d.State.info
+}
+
+func (d *Decoder) playerSlice(capacity int) []PlayerInfo {
+	if d.State == nil {
+		return make([]PlayerInfo, 0, capacity)
+	}
+	d.State.players = d.State.players[:0]
+	return d.State.players
+}
+
+func (d *Decoder) ruleSlice(capacity int) []Rule {
+	if d.State == nil {
+		return make([]Rule, 0, capacity)
+	}
+	d.State.rules = d.State.rules[:0]
+	return d.State.rules
+}
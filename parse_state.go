@@ -0,0 +1,39 @@
+package a2s
+
+// readBufferSize is the size of the buffer a single response is read into.
+const readBufferSize = 4096
+
+
+// ParseState holds the buffers a Client needs to read and parse responses, so
+// they can be recycled across polls of the same server instead of being
+// allocated on every query. Attach it to a Client with WithParseState, or set
+// it as Decoder.State.
+//
+// While a ParseState is attached, the ServerInfo, player slice and rule slice
+// returned by the client are backed by the state and are overwritten by the
+// next query of the same kind. Copy anything that needs to outlive that.
+// The read buffer is not recycled when zero-copy strings are enabled, since
+// the returned strings point into it.
+// A ParseState must not be shared by clients used concurrently.
+type ParseState struct {
+	buffer  []byte
+	info    ServerInfo
+	players []PlayerInfo
+	rules   []Rule
+}
+
+func NewParseState() *ParseState {
+	return &ParseState{
+		buffer: make([]byte, readBufferSize),
+	}
+}
+
+// Reset drops the results held by the state while keeping the allocated
+// capacity, so strings referenced by earlier results can be garbage collected.
+func (s *ParseState) Reset() {
+	s.info = ServerInfo{}
+	clear(s.players)
+	s.players = s.players[:0]
+	clear(s.rules)
+	s.rules = s.rules[:0]
+}
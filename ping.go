@@ -0,0 +1,80 @@
+//go:build !js
+
+package a2s
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+
+// PingStats summarizes a series of RTT probes sent by Client.PingStats.
+type PingStats struct {
+	Sent     int `json:"sent"`
+	Received int `json:"received"`
+	// Loss is the percentage of probes that timed out, from 0 to 100.
+	Loss float64 `json:"loss"`
+
+	Min time.Duration `json:"min"`
+	Avg time.Duration `json:"avg"`
+	Max time.Duration `json:"max"`
+	P95 time.Duration `json:"p95"`
+	// Jitter is the mean absolute difference between consecutive samples.
+	Jitter time.Duration `json:"jitter"`
+}
+
+
+// PingStats sends n RTT probes, waiting interval between them, and summarizes
+// the results. A single sample over UDP is too noisy to select servers by
+// latency; a handful of samples gives a far more stable picture. Probes that
+// time out count as lost. Any other error aborts the series and is returned.
+// If every probe is lost, the latency fields are zero and Loss is 100.
+func (c *Client) PingStats(n int, interval time.Duration) (*PingStats, error) {
+	stats := &PingStats{}
+	samples := make([]time.Duration, 0, n)
+
+	for i := 0; i < n; i++ {
+		if i > 0 && interval > 0 {
+			c.clock.Sleep(interval)
+		}
+
+		stats.Sent++
+		rtt, err := c.RTT()
+		if err != nil {
+			if errors.Is(err, ErrTimeout) {
+				continue
+			}
+			return nil, err
+		}
+		samples = append(samples, rtt)
+	}
+
+	stats.Received = len(samples)
+	if stats.Sent > 0 {
+		stats.Loss = float64(stats.Sent-stats.Received) / float64(stats.Sent) * 100
+	}
+	if len(samples) == 0 {
+		return stats, nil
+	}
+
+	var total, jitter time.Duration
+	for i, rtt := range samples {
+		total += rtt
+		if i > 0 {
+			jitter += (rtt - samples[i-1]).Abs()
+		}
+	}
+	stats.Avg = total / time.Duration(len(samples))
+	if len(samples) > 1 {
+		stats.Jitter = jitter / time.Duration(len(samples)-1)
+	}
+
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	stats.Min = sorted[0]
+	stats.Max = sorted[len(sorted)-1]
+	stats.P95 = sorted[(len(sorted)*95+99)/100-1]
+
+	return stats, nil
+}
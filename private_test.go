@@ -0,0 +1,28 @@
+//go:build !js
+
+package a2s_test
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+
+// TestPrivateProfile checks the guarantee of the a2s_private tag: no package
+// of the module built with it depends on the master subpackage.
+func TestPrivateProfile(t *testing.T) {
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out, err := exec.Command(gobin, "list", "-tags", "a2s_private", "-deps", "./...").Output()
+	if err != nil {
+		t.Fatalf("go list: %v", err)
+	}
+	for _, pkg := range strings.Fields(string(out)) {
+		if strings.HasSuffix(pkg, "/master") {
+			t.Errorf("the a2s_private build depends on %s", pkg)
+		}
+	}
+}
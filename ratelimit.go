@@ -0,0 +1,112 @@
+//go:build !js
+
+package a2s
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+
+// RateLimiter limits the rate of requests sent to each server, with a token
+// bucket per server address. Game servers with query flood protection ban
+// addresses that send too many requests, so a limiter shared by all clients
+// of a process keeps it below their threshold no matter how many clients or
+// goroutines query the same server. See WithRateLimiter.
+//
+// Every request packet counts, so a query that needs a challenge uses two
+// tokens. A RateLimiter is safe for concurrent use.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[netip.AddrPort]*bucket
+	// swept is the number of buckets after the last sweep of full ones.
+	swept int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter that allows rate requests per second to
+// each server, and bursts of up to burst requests. A burst below 1 is
+// raised to 1. A rate of zero or less does not limit requests at all.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(max(burst, 1)),
+		buckets: make(map[netip.AddrPort]*bucket),
+	}
+}
+
+// reserve takes a token for a request to addr at now and returns how long to
+// wait before sending it. The time comes from the clock of the client.
+func (l *RateLimiter) reserve(addr netip.AddrPort, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.buckets[addr]
+	if b == nil {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[addr] = b
+		l.sweep(now)
+	}
+	return b.take(now, l.rate, l.burst)
+}
+
+// take refills the bucket for the time passed since the last call, takes a
+// token and returns how long to wait until the token is actually available.
+func (b *bucket) take(now time.Time, rate, burst float64) time.Duration {
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 || rate <= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// sweep drops the buckets that have refilled completely whenever the map has
+// doubled in size since the last sweep. A full bucket behaves exactly like a
+// missing one.
+func (l *RateLimiter) sweep(now time.Time) {
+	if len(l.buckets) <= max(2*l.swept, 1024) {
+		return
+	}
+	for addr, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, addr)
+		}
+	}
+	l.swept = len(l.buckets)
+}
+
+
+// waitRateLimit waits until the rate limiter allows the next request. If the
+// wait would exhaust the deadline budget of the current operation, it fails
+// with ErrTimeout right away.
+func (c *Client) waitRateLimit() error {
+	if c.limiter == nil {
+		return nil
+	}
+	addr, ok := c.remoteAddrPort()
+	if !ok {
+		return nil
+	}
+
+	delay := c.limiter.reserve(addr, c.clock.Now())
+	if delay <= 0 {
+		return nil
+	}
+	if c.operations > 0 && delay >= c.budgetDeadline.Sub(c.clock.Now()) {
+		return fmt.Errorf("%w: deadline budget exhausted by rate limit", ErrTimeout)
+	}
+	c.clock.Sleep(delay)
+	return nil
+}
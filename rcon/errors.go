@@ -0,0 +1,15 @@
+package rcon
+
+import (
+	"errors"
+)
+
+var (
+	ErrNotConnected      = errors.New("not connected to server")
+	ErrTimeout           = errors.New("request timeout")
+	ErrHandshakeFailed   = errors.New("websocket handshake failed")
+	ErrConnectionClosed  = errors.New("connection closed by server")
+	ErrUnsupportedFrame  = errors.New("unsupported websocket frame")
+	ErrInvalidStatus     = errors.New("invalid status output")
+	ErrMessageTooLarge   = errors.New("websocket message too large")
+)
@@ -0,0 +1,203 @@
+package rcon
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+
+// Status is the parsed output of the `status` console command.
+type Status struct {
+	Hostname string
+	Version  string
+	Map      string
+	Players  []StatusPlayer
+}
+
+// StatusPlayer is a row of the player table printed by `status`. Fields a
+// game does not print are left zero: Rust servers have no user ID, loss or
+// state, and bots on Source servers only have a name and state.
+type StatusPlayer struct {
+	UserID    int
+	Name      string
+	SteamID   string
+	Connected time.Duration
+	Ping      int
+	Loss      int
+	State     string
+	Address   string
+}
+
+
+// ParseStatus parses `status` output from Source engine servers (including
+// CS:GO/CS2 style tables with a slot column) and from Rust servers. It
+// returns an error wrapping ErrInvalidStatus if the output has no hostname
+// line, which every supported game prints first.
+func ParseStatus(output string) (*Status, error) {
+	status := &Status{}
+	hasHostname := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if key, value, ok := splitStatusField(line); ok {
+			switch key {
+			case "hostname":
+				status.Hostname = value
+				hasHostname = true
+			case "version":
+				status.Version = value
+			case "map":
+				if i := strings.Index(value, " at:"); i >= 0 {
+					value = value[:i]
+				}
+				status.Map = strings.TrimSpace(value)
+			}
+			continue
+		}
+
+		var (
+			player StatusPlayer
+			ok     bool
+		)
+		if strings.HasPrefix(line, "#") {
+			player, ok = parseSourcePlayer(line)
+		} else {
+			player, ok = parseRustPlayer(line)
+		}
+		if ok {
+			status.Players = append(status.Players, player)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !hasHostname {
+		return nil, fmt.Errorf("%w: no hostname line", ErrInvalidStatus)
+	}
+
+	return status, nil
+}
+
+
+// splitStatusField splits a "key : value" header line. Player rows never
+// match because their first word is not followed by a colon.
+func splitStatusField(line string) (string, string, bool) {
+	i := strings.Index(line, ":")
+	if i <= 0 {
+		return "", "", false
+	}
+	key := strings.TrimSpace(line[:i])
+	if strings.ContainsAny(key, " \t\"#") {
+		return "", "", false
+	}
+	return strings.ToLower(key), strings.TrimSpace(line[i+1:]), true
+}
+
+// splitQuotedName splits a player row around the quoted name, returning the
+// fields before it, the name and the fields after it.
+func splitQuotedName(line string) ([]string, string, []string, bool) {
+	start := strings.Index(line, "\"")
+	end := strings.LastIndex(line, "\"")
+	if start < 0 || end <= start {
+		return nil, "", nil, false
+	}
+	return strings.Fields(line[:start]), line[start+1 : end], strings.Fields(line[end+1:]), true
+}
+
+// parseSourcePlayer parses a Source player row:
+//
+//	#  2 "Name" STEAM_1:0:123 00:31 50 0 active 196608 1.2.3.4:27005
+//	# 2 1 "Name" STEAM_1:0:123 00:31 50 0 active 196608 1.2.3.4:27005
+//	#  3 "BOT" BOT active
+func parseSourcePlayer(line string) (StatusPlayer, bool) {
+	var player StatusPlayer
+
+	before, name, after, ok := splitQuotedName(strings.TrimPrefix(line, "#"))
+	if !ok || len(before) == 0 || len(after) == 0 {
+		return player, false
+	}
+
+	userID, err := strconv.Atoi(before[0])
+	if err != nil {
+		return player, false
+	}
+	player.UserID = userID
+	player.Name = name
+	player.SteamID = after[0]
+
+	if len(after) == 2 {
+		player.State = after[1]
+		return player, true
+	}
+
+	if len(after) > 1 {
+		player.Connected = parseClockDuration(after[1])
+	}
+	if len(after) > 2 {
+		player.Ping, _ = strconv.Atoi(after[2])
+	}
+	if len(after) > 3 {
+		player.Loss, _ = strconv.Atoi(after[3])
+	}
+	if len(after) > 4 {
+		player.State = after[4]
+	}
+	if len(after) > 6 {
+		player.Address = after[6]
+	}
+
+	return player, true
+}
+
+// parseRustPlayer parses a Rust player row:
+//
+//	76561198000000000 "Name" 50 3600.5s 1.2.3.4:1234 0.0 0
+func parseRustPlayer(line string) (StatusPlayer, bool) {
+	var player StatusPlayer
+
+	before, name, after, ok := splitQuotedName(line)
+	if !ok || len(before) != 1 {
+		return player, false
+	}
+	if _, err := strconv.ParseUint(before[0], 10, 64); err != nil {
+		return player, false
+	}
+
+	player.SteamID = before[0]
+	player.Name = name
+
+	if len(after) > 0 {
+		player.Ping, _ = strconv.Atoi(after[0])
+	}
+	if len(after) > 1 {
+		player.Connected, _ = time.ParseDuration(after[1])
+	}
+	if len(after) > 2 {
+		player.Address = after[2]
+	}
+
+	return player, true
+}
+
+// parseClockDuration parses the MM:SS or HH:MM:SS connection time printed by
+// Source servers. It returns zero for anything else.
+func parseClockDuration(s string) time.Duration {
+	var d time.Duration
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		d = d*60 + time.Duration(n)
+	}
+	return d * time.Second
+}
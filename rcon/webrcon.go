@@ -0,0 +1,216 @@
+// Package rcon implements remote console clients for game servers, starting
+// with the WebSocket based RCON protocol used by Rust (WebRCON).
+//
+// This package is experimental: its API may change between minor releases.
+package rcon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+
+// Message is a WebRCON message. Replies to a command carry the Identifier the
+// command was sent with; console output and chat pushed by the server use
+// Identifier 0 or -1.
+type Message struct {
+	Message    string `json:"Message"`
+	Identifier int    `json:"Identifier"`
+	Type       string `json:"Type,omitempty"`
+	Name       string `json:"Name,omitempty"`
+	Stacktrace string `json:"Stacktrace,omitempty"`
+}
+
+
+// DefaultMaxMessageSize is the largest message a WebClient accepts unless
+// WithMaxMessageSize says otherwise. Rust's console output stays far below it.
+const DefaultMaxMessageSize = 16 << 20
+
+
+// WebClient is a Rust WebRCON client. It talks JSON over a WebSocket
+// connection to ws://host:port/<password>.
+type WebClient struct {
+	conn    net.Conn
+	br      *bufio.Reader
+	timeout time.Duration
+	next    int
+	maxSize int
+
+	mu sync.Mutex
+}
+
+// WebClientOption configures a WebClient.
+type WebClientOption func(*WebClient)
+
+// WithMaxMessageSize limits the size of the messages the server may send, so
+// a broken or hostile server cannot exhaust memory by announcing a huge
+// frame. Larger messages fail with ErrMessageTooLarge and close the
+// connection. The default is DefaultMaxMessageSize.
+func WithMaxMessageSize(n int) WebClientOption {
+	return func(c *WebClient) {
+		c.maxSize = n
+	}
+}
+
+func NewWebClient(timeout time.Duration, opts ...WebClientOption) *WebClient {
+	c := &WebClient{
+		timeout: timeout,
+		maxSize: DefaultMaxMessageSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+
+// Connect dials the server's RCON port and performs the WebSocket handshake,
+// authenticating with the password. Rust rejects a wrong password by refusing
+// the upgrade, so Connect returns an error wrapping ErrHandshakeFailed in that
+// case.
+func (c *WebClient) Connect(addr, password string) error {
+	conn, err := net.DialTimeout("tcp", addr, c.timeout)
+	if err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if err := handshake(conn, br, addr, "/"+url.PathEscape(password)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	c.conn = conn
+	c.br = br
+	return nil
+}
+
+var _ io.Closer = (*WebClient)(nil)
+
+// Close closes the connection. Closing a closed client does nothing.
+func (c *WebClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	writeFrame(c.conn, opClose, nil)
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+
+// Execute runs a console command and returns the server's reply. Messages
+// that arrive in the meantime without the command's identifier (console logs,
+// chat) are discarded; use Read to consume those instead.
+func (c *WebClient) Execute(command string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return "", ErrNotConnected
+	}
+
+	c.next++
+	id := c.next
+
+	payload, err := json.Marshal(Message{
+		Message:    command,
+		Identifier: id,
+		Name:       "WebRcon",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if err := writeFrame(c.conn, opText, payload); err != nil {
+		return "", fmt.Errorf("write error: %w", err)
+	}
+
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			return "", err
+		}
+		if msg.Identifier == id {
+			return msg.Message, nil
+		}
+	}
+}
+
+// Read waits up to the client timeout for the next message pushed by the
+// server, such as console output or chat.
+func (c *WebClient) Read() (*Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.readMessage()
+}
+
+
+// readMessage reads frames until a complete text message has been received,
+// answering pings along the way, and decodes it.
+func (c *WebClient) readMessage() (*Message, error) {
+	var data []byte
+
+	for {
+		fin, opcode, payload, err := readFrame(c.br, c.maxSize-len(data))
+		if err != nil {
+			if errors.Is(err, ErrMessageTooLarge) {
+				// The rest of the frame is still unread, so the stream
+				// cannot be resynchronized.
+				c.conn.Close()
+				c.conn = nil
+				return nil, err
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return nil, ErrTimeout
+			}
+			return nil, fmt.Errorf("read error: %w", err)
+		}
+
+		switch opcode {
+		case opPing:
+			if err := writeFrame(c.conn, opPong, payload); err != nil {
+				return nil, fmt.Errorf("write error: %w", err)
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return nil, ErrConnectionClosed
+		case opText, opBinary, opContinuation:
+			data = append(data, payload...)
+		default:
+			return nil, fmt.Errorf("%w: opcode 0x%X", ErrUnsupportedFrame, opcode)
+		}
+
+		if fin {
+			break
+		}
+	}
+
+	msg := &Message{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("decode error: %w", err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,126 @@
+//go:build !tinygo && !a2s_minimal
+
+package a2s
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+
+// PasswordPattern matches passwords that operators put into server names
+// and MOTD rules, like "scrim tonight | pw: hunter2".
+var PasswordPattern = regexp.MustCompile(`(?i)\b(?:password|passwd|pass|pwd|pw)\b\s*[:=]?\s*\S+`)
+
+// redactedText replaces text matched by a pattern of a Redaction.
+const redactedText = "***"
+
+
+// Redaction removes details from results before they are published, e.g. on
+// a public dashboard fed from private infrastructure. Its methods return
+// redacted copies and never modify their arguments, so they can be passed to
+// WithInfoTransform and similar options, or applied by formatters and
+// gateways just before output. A nil *Redaction redacts nothing.
+type Redaction struct {
+	// Addresses hides IP addresses: the address GoldSource servers report,
+	// keys derived from addresses and addresses formatted with Address.
+	Addresses bool
+	// SteamIDs drops the SteamID of servers and hashes keys derived from
+	// it, which stay stable but no longer reveal it.
+	SteamIDs bool
+	// Patterns are replaced by "***" in server, player and SourceTV names,
+	// keywords and rule values. See PasswordPattern.
+	Patterns []*regexp.Regexp
+}
+
+// Address returns the address of a server as given, or "redacted" if
+// addresses are hidden.
+func (r *Redaction) Address(addr string) string {
+	if r != nil && r.Addresses {
+		return "redacted"
+	}
+	return addr
+}
+
+// Key returns the key with the address or SteamID it reveals replaced by a
+// hash of the key, if those are hidden.
+func (r *Redaction) Key(key ServerKey) ServerKey {
+	if r == nil {
+		return key
+	}
+	kind, _, _ := strings.Cut(string(key), ":")
+	if kind == "addr" && r.Addresses || kind == "steam" && r.SteamIDs {
+		sum := sha256.Sum256([]byte(key))
+		return ServerKey("redacted:" + hex.EncodeToString(sum[:8]))
+	}
+	return key
+}
+
+// Info returns a redacted copy of the info.
+func (r *Redaction) Info(info *ServerInfo) *ServerInfo {
+	if r == nil || info == nil {
+		return info
+	}
+	redacted := *info
+	redacted.Name = r.text(info.Name)
+	redacted.SourceTV.Name = r.text(info.SourceTV.Name)
+	redacted.Keywords = slices.Clone(info.Keywords)
+	for i, keyword := range redacted.Keywords {
+		redacted.Keywords[i] = r.text(keyword)
+	}
+	if r.Addresses {
+		redacted.ReportedAddress = ""
+	}
+	if r.SteamIDs {
+		redacted.SteamID = 0
+	}
+	return &redacted
+}
+
+// Players returns a redacted copy of the players.
+func (r *Redaction) Players(players []PlayerInfo) []PlayerInfo {
+	if r == nil || len(r.Patterns) == 0 {
+		return players
+	}
+	redacted := slices.Clone(players)
+	for i := range redacted {
+		redacted[i].Name = r.text(redacted[i].Name)
+	}
+	return redacted
+}
+
+// Rules returns a redacted copy of the rules.
+func (r *Redaction) Rules(rules Rules) Rules {
+	if r == nil || len(r.Patterns) == 0 {
+		return rules
+	}
+	redacted := slices.Clone(rules)
+	for i := range redacted {
+		redacted[i].Value = r.text(redacted[i].Value)
+	}
+	return redacted
+}
+
+// Snapshot returns a redacted copy of the snapshot.
+func (r *Redaction) Snapshot(s *ServerSnapshot) *ServerSnapshot {
+	if r == nil || s == nil {
+		return s
+	}
+	redacted := *s
+	redacted.Info = r.Info(s.Info)
+	redacted.Players = r.Players(s.Players)
+	redacted.Rules = r.Rules(s.Rules)
+	redacted.Key = r.Key(s.Key)
+	return &redacted
+}
+
+// text replaces every match of the patterns.
+func (r *Redaction) text(s string) string {
+	for _, pattern := range r.Patterns {
+		s = pattern.ReplaceAllString(s, redactedText)
+	}
+	return s
+}
@@ -0,0 +1,112 @@
+//go:build !js
+
+package a2s
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+
+// RetryPolicy decides how often and how quickly a request is sent again.
+// See WithRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is sent at most,
+	// including the first one.
+	MaxAttempts int
+	// Backoff is the wait before the second attempt. Each further wait is
+	// Multiplier times longer, but never longer than MaxBackoff if that is
+	// set. A Multiplier below 1 keeps the wait constant.
+	Backoff    time.Duration
+	Multiplier float64
+	MaxBackoff time.Duration
+	// Jitter randomizes each wait by up to this fraction in either
+	// direction, e.g. 0.2 for ±20%, so many clients retrying at once do not
+	// stay in lockstep. See WithRandSource for reproducible jitter.
+	Jitter float64
+	// Retry decides whether a request that failed with err is sent again.
+	// Challenges are always answered with a new request, as long as
+	// attempts are left. If Retry is nil, nothing else is retried.
+	Retry func(err error) bool
+}
+
+// DefaultRetryPolicy answers up to two challenges per request, waiting
+// 100ms before each new attempt, and retries nothing else.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     100 * time.Millisecond,
+}
+
+// RetryTimeouts is a RetryPolicy.Retry function that retries requests the
+// server did not answer. Over the internet a single lost datagram is common,
+// on a LAN a timeout rather means the server is down.
+func RetryTimeouts(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+// retries reports whether a request that failed with err is sent again.
+func (p *RetryPolicy) retries(err error) bool {
+	if errors.Is(err, ErrChallengeRequired) {
+		return true
+	}
+	return p.Retry != nil && p.Retry(err)
+}
+
+// backoff returns the wait before the given attempt, counted from 0 for the
+// first one. Jitter is drawn from random, or the global source if nil.
+func (p *RetryPolicy) backoff(attempt int, random *rand.Rand) time.Duration {
+	wait := float64(p.Backoff)
+	if p.Multiplier > 1 {
+		for i := 1; i < attempt; i++ {
+			wait *= p.Multiplier
+			if p.MaxBackoff > 0 && wait >= float64(p.MaxBackoff) {
+				break
+			}
+		}
+	}
+	if p.MaxBackoff > 0 {
+		wait = min(wait, float64(p.MaxBackoff))
+	}
+	if p.Jitter > 0 {
+		r := rand.Float64
+		if random != nil {
+			r = random.Float64
+		}
+		wait *= 1 + p.Jitter*(2*r()-1)
+	}
+	return time.Duration(wait)
+}
+
+
+// sendRequest sends a request to the server and waits for a response,
+// retrying as the RetryPolicy of the client allows. If the server still asks
+// for a challenge after the last attempt, it returns ErrTooManyRetries,
+// otherwise the error of the last attempt.
+func (c *Client) sendRequest(packetType byte, payload []byte, expectResponse byte) ([]byte, error) {
+	var err error
+	for attempt := 0; attempt < max(c.retry.MaxAttempts, 1); attempt++ {
+		if attempt > 0 {
+			wait := c.retry.backoff(attempt, c.random)
+			if c.budget.Total > 0 && c.operations > 0 && wait >= c.budgetDeadline.Sub(c.clock.Now()) {
+				break
+			}
+			c.scope.retried()
+			c.clock.Sleep(wait)
+		}
+
+		var response []byte
+		response, err = c.sendRequestRaw(packetType, payload, expectResponse)
+		if err == nil {
+			return response, nil
+		}
+		if !c.retry.retries(err) {
+			return nil, err
+		}
+	}
+
+	if errors.Is(err, ErrChallengeRequired) {
+		return nil, ErrTooManyRetries
+	}
+	return nil, err
+}
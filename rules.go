@@ -0,0 +1,134 @@
+package a2s
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Rules is the list of rules returned by GetRules, in the order the server
+// sent them. Names keep the casing the server used.
+type Rules []Rule
+
+
+// Get returns the value of the named rule. Cvar names differ in case between
+// games, so the lookup is case-insensitive; an exact match is preferred if the
+// server sends the same name in several casings.
+func (r Rules) Get(name string) (string, bool) {
+	if rule := r.Lookup(name); rule != nil {
+		return rule.Value, true
+	}
+	return "", false
+}
+
+// Lookup returns the named rule, matched like Get, or nil if the server did
+// not send it. The returned rule's Name has the server's original casing.
+func (r Rules) Lookup(name string) *Rule {
+	var folded *Rule
+	for i := range r {
+		if r[i].Name == name {
+			return &r[i]
+		}
+		if folded == nil && strings.EqualFold(r[i].Name, name) {
+			folded = &r[i]
+		}
+	}
+	return folded
+}
+
+// Map returns the rules as a map keyed by lower-cased name. If names collide
+// after lower-casing, the last rule wins.
+func (r Rules) Map() map[string]string {
+	m := make(map[string]string, len(r))
+	for _, rule := range r {
+		m[strings.ToLower(rule.Name)] = rule.Value
+	}
+	return m
+}
+
+
+// GetInt returns the named rule's value as an integer. It returns an error
+// wrapping ErrRuleNotFound if the rule is missing, or a *CoercionError if the
+// value is not an integer.
+func (r Rules) GetInt(name string) (int64, error) {
+	rule := r.Lookup(name)
+	if rule == nil {
+		return 0, fmt.Errorf("%w: %s", ErrRuleNotFound, name)
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(rule.Value), 10, 64)
+	if err != nil {
+		return 0, &CoercionError{Rule: rule.Name, Value: rule.Value, Type: "int", Err: err}
+	}
+	return n, nil
+}
+
+// GetFloat returns the named rule's value as a float. It returns an error
+// wrapping ErrRuleNotFound if the rule is missing, or a *CoercionError if the
+// value is not a number.
+func (r Rules) GetFloat(name string) (float64, error) {
+	rule := r.Lookup(name)
+	if rule == nil {
+		return 0, fmt.Errorf("%w: %s", ErrRuleNotFound, name)
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(rule.Value), 64)
+	if err != nil {
+		return 0, &CoercionError{Rule: rule.Name, Value: rule.Value, Type: "float", Err: err}
+	}
+	return f, nil
+}
+
+
+// RuleChange is a difference between two sets of rules, see DiffRules.
+type RuleChange struct {
+	// Name has the casing of the newer rules, or of the older ones if the
+	// rule was removed.
+	Name string `json:"name"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+	// Added and Removed are set if the rule is missing from the older or
+	// newer rules. Otherwise its value changed.
+	Added   bool `json:"added,omitempty"`
+	Removed bool `json:"removed,omitempty"`
+}
+
+// DiffRules returns the rules that were added, removed or changed their value
+// from old to new, sorted by name. Names are matched case-insensitively like
+// Map does, so a server that changes the casing of a cvar does not report it
+// as removed and added again.
+func DiffRules(old, new Rules) []RuleChange {
+	oldByName, newByName := byLowerName(old), byLowerName(new)
+
+	var changes []RuleChange
+	for key, rule := range newByName {
+		before, ok := oldByName[key]
+		switch {
+		case !ok:
+			changes = append(changes, RuleChange{Name: rule.Name, New: rule.Value, Added: true})
+		case before.Value != rule.Value:
+			changes = append(changes, RuleChange{Name: rule.Name, Old: before.Value, New: rule.Value})
+		}
+	}
+	for key, rule := range oldByName {
+		if _, ok := newByName[key]; !ok {
+			changes = append(changes, RuleChange{Name: rule.Name, Old: rule.Value, Removed: true})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b RuleChange) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return changes
+}
+
+// byLowerName indexes the rules by lower-cased name. If names collide, the
+// last rule wins, as in Map.
+func byLowerName(rules Rules) map[string]Rule {
+	m := make(map[string]Rule, len(rules))
+	for _, rule := range rules {
+		m[strings.ToLower(rule.Name)] = rule
+	}
+	return m
+}
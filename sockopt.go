@@ -0,0 +1,80 @@
+//go:build !js
+
+package a2s
+
+import (
+	"strings"
+	"syscall"
+)
+
+
+// SocketOptions tune the UDP sockets of a client or multiplexer, see
+// WithSocketOptions and WithMultiplexerSocketOptions. The zero value leaves
+// every setting at the system default.
+type SocketOptions struct {
+	// TOS sets the type of service byte of IPv4 packets and the traffic
+	// class of IPv6 packets. DSCP marking goes into the upper six bits, so
+	// expedited forwarding (DSCP 46) is a TOS of 46<<2.
+	TOS int
+	// TTL sets the time to live of IPv4 packets and the hop limit of IPv6
+	// packets.
+	TTL int
+	// ReusePort sets SO_REUSEPORT, so several sockets can be bound to the
+	// same local port, e.g. by WithLocalAddr.
+	ReusePort bool
+	// Control is called with the raw socket after the options above were
+	// set and before it is bound, for anything else, like net.Dialer.Control.
+	Control func(network, address string, c syscall.RawConn) error
+}
+
+func (o SocketOptions) isZero() bool {
+	return o.TOS == 0 && o.TTL == 0 && !o.ReusePort && o.Control == nil
+}
+
+// control returns the control function that applies the options, or nil if
+// there is nothing to apply.
+func (o SocketOptions) control() func(network, address string, c syscall.RawConn) error {
+	if o.isZero() {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		if o.TOS != 0 || o.TTL != 0 || o.ReusePort {
+			var err error
+			controlErr := c.Control(func(fd uintptr) {
+				err = setSocketOptions(fd, strings.HasSuffix(network, "6"), o)
+			})
+			if controlErr != nil {
+				return controlErr
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if o.Control != nil {
+			return o.Control(network, address, c)
+		}
+		return nil
+	}
+}
+
+// chainControl returns a control function calling every non-nil one of fns
+// in turn, or nil if there are none.
+func chainControl(fns ...func(network, address string, c syscall.RawConn) error) func(network, address string, c syscall.RawConn) error {
+	var chain []func(network, address string, c syscall.RawConn) error
+	for _, fn := range fns {
+		if fn != nil {
+			chain = append(chain, fn)
+		}
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		for _, fn := range chain {
+			if err := fn(network, address, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,10 @@
+//go:build (darwin || freebsd || netbsd || openbsd || dragonfly) && !tinygo
+
+package a2s
+
+import "syscall"
+
+
+func soReusePort() int {
+	return syscall.SO_REUSEPORT
+}
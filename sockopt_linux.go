@@ -0,0 +1,18 @@
+//go:build linux && !tinygo
+
+package a2s
+
+import (
+	"runtime"
+	"strings"
+)
+
+
+// soReusePort returns SO_REUSEPORT, which the syscall package does not
+// define on Linux.
+func soReusePort() int {
+	if strings.HasPrefix(runtime.GOARCH, "mips") {
+		return 0x200
+	}
+	return 0xf
+}
@@ -0,0 +1,15 @@
+//go:build !js && (tinygo || !(linux || darwin || freebsd || netbsd || openbsd || dragonfly))
+
+package a2s
+
+import (
+	"fmt"
+	"runtime"
+)
+
+
+// setSocketOptions fails: TOS, TTL and SO_REUSEPORT are only supported on
+// Linux and the BSDs. SocketOptions.Control works everywhere.
+func setSocketOptions(fd uintptr, ipv6 bool, o SocketOptions) error {
+	return fmt.Errorf("%w: socket options on %s", ErrUnsupportedFeature, runtime.GOOS)
+}
@@ -0,0 +1,40 @@
+//go:build (linux || darwin || freebsd || netbsd || openbsd || dragonfly) && !tinygo
+
+package a2s
+
+import (
+	"fmt"
+	"syscall"
+)
+
+
+// setSocketOptions sets the TOS, TTL and SO_REUSEPORT of the socket. IPv6
+// sockets may carry IPv4 traffic as well, so the IPv4 options are set on
+// them too where the system allows it.
+func setSocketOptions(fd uintptr, ipv6 bool, o SocketOptions) error {
+	s := int(fd)
+	if o.ReusePort {
+		if err := syscall.SetsockoptInt(s, syscall.SOL_SOCKET, soReusePort(), 1); err != nil {
+			return fmt.Errorf("SO_REUSEPORT: %w", err)
+		}
+	}
+	if o.TOS != 0 {
+		err := syscall.SetsockoptInt(s, syscall.IPPROTO_IP, syscall.IP_TOS, o.TOS)
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, o.TOS)
+		}
+		if err != nil {
+			return fmt.Errorf("TOS: %w", err)
+		}
+	}
+	if o.TTL != 0 {
+		err := syscall.SetsockoptInt(s, syscall.IPPROTO_IP, syscall.IP_TTL, o.TTL)
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, o.TTL)
+		}
+		if err != nil {
+			return fmt.Errorf("TTL: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+//go:build !js
+
+package a2s
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"time"
+)
+
+
+// SOCKS5 protocol constants, see RFC 1928 and RFC 1929.
+const (
+	socksVersion         = 0x05
+	socksAuthNone        = 0x00
+	socksAuthPassword    = 0x02
+	socksCmdUDPAssociate = 0x03
+	socksAtypIPv4        = 0x01
+	socksAtypDomain      = 0x03
+	socksAtypIPv6        = 0x04
+	socksReplySucceeded  = 0x00
+)
+
+// socksProxy is the proxy configured with WithSOCKS5.
+type socksProxy struct {
+	addr     string
+	username string
+	password string
+}
+
+
+// validate checks that the credentials fit into the single length byte RFC
+// 1929 gives them.
+func (p *socksProxy) validate() error {
+	if len(p.username) > 255 {
+		return fmt.Errorf("%w: username longer than 255 bytes", ErrProxyFailed)
+	}
+	if len(p.password) > 255 {
+		return fmt.Errorf("%w: password longer than 255 bytes", ErrProxyFailed)
+	}
+	return nil
+}
+
+// socksConn is a datagram connection to a server relayed by a SOCKS5 proxy.
+// The TCP control connection must stay open for as long as the association
+// is used; the proxy drops it when the control connection closes.
+type socksConn struct {
+	control net.Conn
+	relay   *net.UDPConn
+	remote  netip.AddrPort
+	buffer  []byte
+}
+
+// dialSOCKS5 sets up a UDP association with the proxy for talking to the
+// server at addr. The handshake is limited to the timeout of the client.
+func (c *Client) dialSOCKS5(addr netip.AddrPort) (net.Conn, error) {
+	proxy := c.socks
+	if err := proxy.validate(); err != nil {
+		return nil, err
+	}
+	control, err := net.DialTimeout("tcp", proxy.addr, c.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProxyFailed, err)
+	}
+	control.SetDeadline(time.Now().Add(c.timeout))
+
+	relay, err := socksAssociate(control, proxy)
+	if err != nil {
+		control.Close()
+		return nil, err
+	}
+	if !relay.Addr().IsValid() || relay.Addr().IsUnspecified() {
+		// The relay listens on the address the control connection reached.
+		proxyAddr, _ := netip.ParseAddrPort(control.RemoteAddr().String())
+		relay = netip.AddrPortFrom(proxyAddr.Addr(), relay.Port())
+	}
+	conn, err := net.DialUDP("udp", nil, net.UDPAddrFromAddrPort(relay))
+	if err != nil {
+		control.Close()
+		return nil, err
+	}
+	control.SetDeadline(time.Time{})
+
+	return &socksConn{
+		control: control,
+		relay:   conn,
+		remote:  netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port()),
+	}, nil
+}
+
+// socksAssociate negotiates authentication on the control connection and
+// requests a UDP association. It returns the address of the relay.
+func socksAssociate(control net.Conn, proxy *socksProxy) (netip.AddrPort, error) {
+	method := byte(socksAuthNone)
+	if proxy.username != "" {
+		method = socksAuthPassword
+	}
+	if _, err := control.Write([]byte{socksVersion, 1, method}); err != nil {
+		return netip.AddrPort{}, fmt.Errorf("%w: %w", ErrProxyFailed, err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(control, reply); err != nil {
+		return netip.AddrPort{}, fmt.Errorf("%w: %w", ErrProxyFailed, err)
+	}
+	if reply[0] != socksVersion || reply[1] != method {
+		return netip.AddrPort{}, fmt.Errorf("%w: authentication method rejected", ErrProxyFailed)
+	}
+
+	if method == socksAuthPassword {
+		request := []byte{0x01, byte(len(proxy.username))}
+		request = append(request, proxy.username...)
+		request = append(request, byte(len(proxy.password)))
+		request = append(request, proxy.password...)
+		if _, err := control.Write(request); err != nil {
+			return netip.AddrPort{}, fmt.Errorf("%w: %w", ErrProxyFailed, err)
+		}
+		if _, err := io.ReadFull(control, reply); err != nil {
+			return netip.AddrPort{}, fmt.Errorf("%w: %w", ErrProxyFailed, err)
+		}
+		if reply[1] != 0x00 {
+			return netip.AddrPort{}, fmt.Errorf("%w: wrong username or password", ErrProxyFailed)
+		}
+	}
+
+	// The client address of the association is not known before the relay
+	// socket exists, so it is left unspecified as RFC 1928 allows.
+	request := []byte{socksVersion, socksCmdUDPAssociate, 0x00, socksAtypIPv4, 0, 0, 0, 0, 0, 0}
+	if _, err := control.Write(request); err != nil {
+		return netip.AddrPort{}, fmt.Errorf("%w: %w", ErrProxyFailed, err)
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(control, header); err != nil {
+		return netip.AddrPort{}, fmt.Errorf("%w: %w", ErrProxyFailed, err)
+	}
+	if header[1] != socksReplySucceeded {
+		return netip.AddrPort{}, fmt.Errorf("%w: UDP associate failed with reply 0x%02X", ErrProxyFailed, header[1])
+	}
+	relay, err := readSOCKSAddr(control, header[3])
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("%w: %w", ErrProxyFailed, err)
+	}
+	return relay, nil
+}
+
+// readSOCKSAddr reads an address of the given type and a port. Domain names
+// are not resolved and yield an invalid address with the port.
+func readSOCKSAddr(r io.Reader, atyp byte) (netip.AddrPort, error) {
+	var size int
+	switch atyp {
+	case socksAtypIPv4:
+		size = 4
+	case socksAtypIPv6:
+		size = 16
+	case socksAtypDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(r, length); err != nil {
+			return netip.AddrPort{}, err
+		}
+		size = int(length[0])
+	default:
+		return netip.AddrPort{}, fmt.Errorf("unknown address type 0x%02X", atyp)
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return netip.AddrPort{}, err
+	}
+	port := binary.BigEndian.Uint16(data[size:])
+	if atyp == socksAtypDomain {
+		return netip.AddrPortFrom(netip.Addr{}, port), nil
+	}
+	ip, _ := netip.AddrFromSlice(data[:size])
+	return netip.AddrPortFrom(ip, port), nil
+}
+
+
+// Write sends b to the server, wrapped in the SOCKS5 UDP request header.
+func (c *socksConn) Write(b []byte) (int, error) {
+	packet := make([]byte, 0, 22+len(b))
+	packet = append(packet, 0, 0, 0)
+	if c.remote.Addr().Is4() {
+		packet = append(packet, socksAtypIPv4)
+	} else {
+		packet = append(packet, socksAtypIPv6)
+	}
+	packet = append(packet, c.remote.Addr().AsSlice()...)
+	packet = binary.BigEndian.AppendUint16(packet, c.remote.Port())
+	packet = append(packet, b...)
+
+	if _, err := c.relay.Write(packet); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Read reads the next datagram relayed from the server into b, without the
+// SOCKS5 header. Datagrams from other sources and fragments, which the
+// protocol allows but no proxy in practice sends, are dropped.
+func (c *socksConn) Read(b []byte) (int, error) {
+	if len(c.buffer) < len(b)+22 {
+		c.buffer = make([]byte, len(b)+22)
+	}
+	for {
+		n, err := c.relay.Read(c.buffer)
+		if err != nil {
+			return 0, err
+		}
+		payload, from, ok := parseSOCKSDatagram(c.buffer[:n])
+		if !ok || from != c.remote {
+			continue
+		}
+		return copy(b, payload), nil
+	}
+}
+
+// parseSOCKSDatagram splits a relayed datagram into its payload and source.
+func parseSOCKSDatagram(data []byte) ([]byte, netip.AddrPort, bool) {
+	if len(data) < 4 || data[2] != 0 {
+		return nil, netip.AddrPort{}, false
+	}
+	size := 4
+	if data[3] == socksAtypIPv6 {
+		size = 16
+	} else if data[3] != socksAtypIPv4 {
+		return nil, netip.AddrPort{}, false
+	}
+	if len(data) < 4+size+2 {
+		return nil, netip.AddrPort{}, false
+	}
+	ip, _ := netip.AddrFromSlice(data[4 : 4+size])
+	port := binary.BigEndian.Uint16(data[4+size:])
+	return data[4+size+2:], netip.AddrPortFrom(ip.Unmap(), port), true
+}
+
+func (c *socksConn) Close() error {
+	c.control.Close()
+	return c.relay.Close()
+}
+
+func (c *socksConn) LocalAddr() net.Addr {
+	return c.relay.LocalAddr()
+}
+
+// RemoteAddr returns the address of the server, not of the relay.
+func (c *socksConn) RemoteAddr() net.Addr {
+	return net.UDPAddrFromAddrPort(c.remote)
+}
+
+func (c *socksConn) SetDeadline(t time.Time) error {
+	return c.relay.SetDeadline(t)
+}
+
+func (c *socksConn) SetReadDeadline(t time.Time) error {
+	return c.relay.SetReadDeadline(t)
+}
+
+func (c *socksConn) SetWriteDeadline(t time.Time) error {
+	return c.relay.SetWriteDeadline(t)
+}
@@ -0,0 +1,26 @@
+//go:build !js
+
+package a2s
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+
+func TestSOCKS5LongCredentials(t *testing.T) {
+	long := strings.Repeat("x", 256)
+	for _, opt := range []Option{
+		WithSOCKS5("127.0.0.1:1080", long, "password"),
+		WithSOCKS5("127.0.0.1:1080", "user", long),
+	} {
+		client := NewClient(time.Second, opt)
+		err := client.Connect("127.0.0.1:27015")
+		if !errors.Is(err, ErrProxyFailed) {
+			t.Errorf("Connect returned %v, want ErrProxyFailed", err)
+		}
+		client.Close()
+	}
+}
@@ -0,0 +1,73 @@
+//go:build !js
+
+package a2s
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/netip"
+	"sync/atomic"
+)
+
+
+// SourcePolicy selects which address of a SourcePool a query is sent from.
+type SourcePolicy int
+
+const (
+	// SourceRoundRobin spreads the servers evenly across the addresses.
+	SourceRoundRobin SourcePolicy = iota
+	// SourceSticky always queries a server from the same address, even
+	// across restarts, so hosts that limit the queries per source address
+	// see a steady rate from a single one.
+	SourceSticky
+)
+
+// SourcePool holds the local addresses of a multi-homed host to send queries
+// from, to spread the query load and stay below per-source rate limits of
+// strict hosts. Servers are only queried from addresses of their own family.
+// See WithSourcePool and WithMultiplexerSources.
+//
+// A SourcePool is safe for concurrent use.
+type SourcePool struct {
+	policy SourcePolicy
+	ipv4   []netip.Addr
+	ipv6   []netip.Addr
+	next   atomic.Uint64
+}
+
+// NewSourcePool returns a pool of the given local addresses.
+func NewSourcePool(policy SourcePolicy, addrs ...netip.Addr) *SourcePool {
+	p := &SourcePool{policy: policy}
+	for _, addr := range addrs {
+		if addr = addr.Unmap(); addr.Is4() {
+			p.ipv4 = append(p.ipv4, addr)
+		} else {
+			p.ipv6 = append(p.ipv6, addr)
+		}
+	}
+	return p
+}
+
+// addrs returns every address of the pool.
+func (p *SourcePool) addrs() []netip.Addr {
+	return append(append([]netip.Addr{}, p.ipv4...), p.ipv6...)
+}
+
+// pick returns the address to query the server at dest from.
+func (p *SourcePool) pick(dest netip.AddrPort) (netip.Addr, error) {
+	candidates := p.ipv6
+	if dest.Addr().Unmap().Is4() {
+		candidates = p.ipv4
+	}
+	if len(candidates) == 0 {
+		return netip.Addr{}, fmt.Errorf("no source address of the family of %s", dest)
+	}
+
+	if p.policy == SourceSticky {
+		h := fnv.New64a()
+		b, _ := netip.AddrPortFrom(dest.Addr().Unmap(), dest.Port()).MarshalBinary()
+		h.Write(b)
+		return candidates[h.Sum64()%uint64(len(candidates))], nil
+	}
+	return candidates[(p.next.Add(1)-1)%uint64(len(candidates))], nil
+}
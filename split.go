@@ -0,0 +1,288 @@
+package a2s
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+
+// SplitInfo describes a response that the server split into several packets.
+type SplitInfo struct {
+	// ID is the answer ID shared by all fragments, without the compression bit.
+	ID    int32
+	Total int
+	// Size is the maximum fragment size announced in the split header. It is
+	// 0 for servers that omit the field, like some protocol 7 era Source
+	// games, and for GoldSource servers, whose header has no such field.
+	Size int
+	// MaxFragment is the size of the largest fragment payload received, which
+	// is the implied fragment size if the server does not announce one.
+	MaxFragment int
+	Compressed  bool
+	GoldSource  bool
+	// Checksum is the result of the CRC32 check of compressed responses.
+	Checksum ChecksumStatus
+}
+
+
+// ChecksumStatus is the result of verifying the CRC32 checksum that compressed
+// split responses carry. Uncompressed responses have no checksum.
+type ChecksumStatus byte
+
+const (
+	// ChecksumNone means no checksummed response was received.
+	ChecksumNone ChecksumStatus = iota
+	ChecksumVerified
+	// ChecksumMismatch means a response did not match its checksum. Unless
+	// WithoutChecksumVerification is set, that query fails with
+	// ErrChecksumMismatch.
+	ChecksumMismatch
+)
+
+// String returns "none", "verified" or "mismatch".
+func (s ChecksumStatus) String() string {
+	switch s {
+	case ChecksumNone:
+		return "none"
+	case ChecksumVerified:
+		return "verified"
+	case ChecksumMismatch:
+		return "mismatch"
+	}
+	return fmt.Sprintf("ChecksumStatus(%d)", byte(s))
+}
+
+func (s ChecksumStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *ChecksumStatus) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "none":
+		*s = ChecksumNone
+	case "verified":
+		*s = ChecksumVerified
+	case "mismatch":
+		*s = ChecksumMismatch
+	default:
+		return fmt.Errorf("unknown checksum status %q", text)
+	}
+	return nil
+}
+
+
+// splitFormat is the layout of the split header that follows the answer ID.
+type splitFormat byte
+
+const (
+	splitUnknown splitFormat = iota
+	// Total and number bytes, then the fragment size.
+	splitSource
+	// Total and number bytes only.
+	splitSourceNoSize
+	// A single byte holding the number in the upper and the total in the
+	// lower four bits.
+	splitGoldSource
+)
+
+const (
+	// maxSplitResponses bounds how many split responses are collected at
+	// once. Besides the awaited one, fragments of earlier responses that
+	// arrived too late may still be in flight.
+	maxSplitResponses = 4
+	// maxPendingFragments bounds how many fragments are buffered while
+	// waiting for the first one, which tells the header layout.
+	maxPendingFragments = 16
+	// maxDecompressedSize bounds the announced size of compressed responses.
+	maxDecompressedSize = 1 << 20
+)
+
+var singlePacketHeader = []byte{0xFF, 0xFF, 0xFF, 0xFF}
+
+
+// splitCollector sorts split packets by answer ID and assembles each response.
+type splitCollector struct {
+	responses map[int32]*splitAssembler
+}
+
+// add adds a split packet, without its 0xFFFFFFFE header, and returns the
+// assembler of its response once all fragments of it have been received.
+func (c *splitCollector) add(data []byte) (*splitAssembler, error) {
+	if len(data) < 5 {
+		return nil, ErrShortResponse
+	}
+
+	id := int32(binary.LittleEndian.Uint32(data) &^ 0x80000000)
+	if c.responses == nil {
+		c.responses = make(map[int32]*splitAssembler)
+	}
+	assembler := c.responses[id]
+	if assembler == nil {
+		if len(c.responses) >= maxSplitResponses {
+			return nil, fmt.Errorf("%w: too many interleaved split responses", ErrInvalidResponse)
+		}
+		assembler = &splitAssembler{}
+		c.responses[id] = assembler
+	}
+
+	done, err := assembler.add(data)
+	if err != nil || !done {
+		return nil, err
+	}
+	return assembler, nil
+}
+
+
+// splitAssembler collects the fragments of a split response. The header
+// layout is not announced anywhere, so it is detected from the first
+// fragment, whose payload starts with the 0xFFFFFFFF single packet header.
+// Fragments arriving before the first one are kept until the layout is known.
+type splitAssembler struct {
+	format    splitFormat
+	started   bool
+	pending   [][]byte
+	fragments [][]byte
+	received  int
+	info      SplitInfo
+
+	// Only set for compressed responses.
+	decompressedSize uint32
+	checksum         uint32
+}
+
+// add adds a split packet of the response, without its 0xFFFFFFFE header, and
+// reports whether all fragments have been received. The packet is copied, so
+// its buffer may be reused.
+func (a *splitAssembler) add(data []byte) (bool, error) {
+	if len(data) < 5 {
+		return false, ErrShortResponse
+	}
+
+	if !a.started {
+		rawID := binary.LittleEndian.Uint32(data)
+		a.started = true
+		a.info.ID = int32(rawID &^ 0x80000000)
+		a.info.Compressed = rawID&0x80000000 != 0
+	}
+
+	data = bytes.Clone(data)
+	if a.format == splitUnknown {
+		a.format = a.detectFormat(data)
+		if a.format == splitUnknown {
+			if len(a.pending) >= maxPendingFragments {
+				return false, fmt.Errorf("%w: unrecognized split header", ErrInvalidResponse)
+			}
+			a.pending = append(a.pending, data)
+			return false, nil
+		}
+
+		pending := a.pending
+		a.pending = nil
+		for _, fragment := range pending {
+			if _, err := a.addFragment(fragment); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return a.addFragment(data)
+}
+
+// detectFormat tells the header layout from the first fragment. Compressed
+// responses were only introduced with the size field, so they always use it.
+// It returns splitUnknown for any other fragment.
+func (a *splitAssembler) detectFormat(data []byte) splitFormat {
+	if a.info.Compressed {
+		return splitSource
+	}
+
+	switch {
+	case len(data) >= 9 && data[4]>>4 == 0 && bytes.Equal(data[5:9], singlePacketHeader):
+		return splitGoldSource
+	case len(data) >= 12 && data[5] == 0 && bytes.Equal(data[8:12], singlePacketHeader):
+		return splitSource
+	case len(data) >= 10 && data[5] == 0 && bytes.Equal(data[6:10], singlePacketHeader):
+		return splitSourceNoSize
+	}
+	return splitUnknown
+}
+
+func (a *splitAssembler) addFragment(data []byte) (bool, error) {
+	var total, number, offset int
+
+	switch a.format {
+	case splitGoldSource:
+		total, number, offset = int(data[4]&0x0F), int(data[4]>>4), 5
+		a.info.GoldSource = true
+	case splitSource, splitSourceNoSize:
+		if len(data) < 6 {
+			return false, ErrShortResponse
+		}
+		total, number, offset = int(data[4]), int(data[5]), 6
+		if a.format == splitSource {
+			if len(data) < 8 {
+				return false, ErrShortResponse
+			}
+			a.info.Size = int(binary.LittleEndian.Uint16(data[6:8]))
+			offset = 8
+		}
+		if a.info.Compressed && number == 0 {
+			if len(data) < offset+8 {
+				return false, ErrShortResponse
+			}
+			a.decompressedSize = binary.LittleEndian.Uint32(data[offset:])
+			a.checksum = binary.LittleEndian.Uint32(data[offset+4:])
+			offset += 8
+		}
+	}
+
+	if total == 0 || number >= total {
+		return false, fmt.Errorf("%w: split fragment %d of %d", ErrInvalidResponse, number, total)
+	}
+	if a.fragments == nil {
+		a.fragments = make([][]byte, total)
+		a.info.Total = total
+	} else if total != a.info.Total {
+		return false, fmt.Errorf("%w: split fragment count changed from %d to %d", ErrInvalidResponse, a.info.Total, total)
+	}
+
+	if a.fragments[number] == nil {
+		payload := data[offset:]
+		a.fragments[number] = payload
+		a.received++
+		a.info.MaxFragment = max(a.info.MaxFragment, len(payload))
+	}
+	return a.received == a.info.Total, nil
+}
+
+// payload returns the fragments joined in order.
+func (a *splitAssembler) payload() []byte {
+	return bytes.Join(a.fragments, nil)
+}
+
+// decompress decompresses the joined payload of a compressed response and
+// records whether its CRC32 checksum matched in info.Checksum.
+func (a *splitAssembler) decompress() ([]byte, error) {
+	if a.decompressedSize > maxDecompressedSize {
+		return nil, fmt.Errorf("%w: decompressed size %d too large", ErrInvalidResponse, a.decompressedSize)
+	}
+
+	reader := bzip2.NewReader(bytes.NewReader(a.payload()))
+	data, err := io.ReadAll(io.LimitReader(reader, int64(a.decompressedSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	if len(data) != int(a.decompressedSize) {
+		return nil, fmt.Errorf("%w: decompressed %d bytes, expected %d", ErrInvalidResponse, len(data), a.decompressedSize)
+	}
+
+	a.info.Checksum = ChecksumVerified
+	if crc32.ChecksumIEEE(data) != a.checksum {
+		a.info.Checksum = ChecksumMismatch
+	}
+	return data, nil
+}
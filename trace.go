@@ -0,0 +1,145 @@
+//go:build !js
+
+package a2s
+
+import "fmt"
+
+
+// Tracer starts spans around the operations of a Client, see WithTracer. The
+// interface follows the OpenTelemetry tracing API closely, so an adapter to
+// an OpenTelemetry tracer is only a few lines, while this package does not
+// depend on it.
+//
+// Spans are started for Connect, every query (GetInfo, GetPlayers, GetRules,
+// QueryAll and Ping) and every single request/response exchange below them,
+// which includes challenge exchanges and retries.
+type Tracer interface {
+	// Start starts a span. parent is the span of the enclosing operation of
+	// the same client, or nil for a top-level operation, in which case the
+	// adapter may attach the span to a parent of its own choosing.
+	Start(parent Span, name string, attrs ...Attribute) Span
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// RecordError records the error the operation failed with and marks the
+	// span as failed.
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key-value pair attached to a span. Value is a string, int
+// or bool.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+
+// Attribute keys set on spans. The server keys follow the OpenTelemetry
+// semantic conventions.
+const (
+	// The host and port of the queried server.
+	AttrServerAddress = "server.address"
+	AttrServerPort    = "server.port"
+	// The query kind: "info", "players", "rules", "all" or "ping".
+	AttrQuery = "a2s.query"
+	// The number of requests resent after a challenge or sent to fall back
+	// to an alternative request, set on query spans.
+	AttrRetries = "a2s.retries"
+	// The request type byte, as "0x54", set on exchange spans. For text
+	// queries it is the first letter of the query.
+	AttrRequestType = "a2s.request.type"
+	// The size of the request and response in bytes, set on exchange spans.
+	AttrRequestSize  = "a2s.request.size"
+	AttrResponseSize = "a2s.response.size"
+	// Whether the server answered the exchange with a challenge.
+	AttrChallenge = "a2s.challenge"
+)
+
+// Span names.
+const (
+	SpanConnect  = "a2s.Connect"
+	SpanGetInfo  = "a2s.GetInfo"
+	SpanPlayers  = "a2s.GetPlayers"
+	SpanRules    = "a2s.GetRules"
+	SpanQueryAll = "a2s.QueryAll"
+	SpanPing     = "a2s.Ping"
+	SpanExchange = "a2s.exchange"
+)
+
+
+// traceScope is an operation traced by the client. Scopes nest: the scope of
+// GetInfo called by QueryAll has the QueryAll scope as its parent.
+type traceScope struct {
+	client  *Client
+	span    Span
+	parent  *traceScope
+	retries int
+}
+
+// trace starts a span for an operation and makes it the current scope of the
+// client. It returns nil if the client has no tracer; all methods of
+// traceScope can be called on nil.
+func (c *Client) trace(name string, attrs ...Attribute) *traceScope {
+	if c.tracer == nil {
+		return nil
+	}
+
+	var parent Span
+	if c.scope != nil {
+		parent = c.scope.span
+	}
+	attrs = append(attrs, c.serverAttributes()...)
+	scope := &traceScope{
+		client: c,
+		span:   c.tracer.Start(parent, name, attrs...),
+		parent: c.scope,
+	}
+	c.scope = scope
+	return scope
+}
+
+// retried counts a resent request of the operation.
+func (s *traceScope) retried() {
+	if s != nil {
+		s.retries++
+	}
+}
+
+// end ends the span, recording err if it is not nil, and restores the
+// parent scope.
+func (s *traceScope) end(err error) {
+	if s == nil {
+		return
+	}
+
+	if s.retries > 0 {
+		s.span.SetAttributes(Attribute{AttrRetries, s.retries})
+		if s.parent != nil {
+			s.parent.retries += s.retries
+		}
+	}
+	if err != nil {
+		s.span.RecordError(err)
+	}
+	s.span.End()
+	s.client.scope = s.parent
+}
+
+// serverAttributes returns the address attributes of the connected server.
+func (c *Client) serverAttributes() []Attribute {
+	addr, ok := c.remoteAddrPort()
+	if !ok {
+		return nil
+	}
+	return []Attribute{
+		{AttrServerAddress, addr.Addr().String()},
+		{AttrServerPort, int(addr.Port())},
+	}
+}
+
+func packetTypeAttribute(b byte) string {
+	return fmt.Sprintf("0x%02X", b)
+}
@@ -0,0 +1,22 @@
+//go:build !js
+
+package a2s
+
+
+// transforms are the hooks registered with WithInfoTransform and similar
+// options, run on every response after it passed the validators.
+type transforms struct {
+	info     []func(*ServerInfo) *ServerInfo
+	players  []func([]PlayerInfo) []PlayerInfo
+	rules    []func(Rules) Rules
+	snapshot []func(*ServerSnapshot) *ServerSnapshot
+}
+
+// transform passes the value through the hooks in the order they were
+// registered.
+func transform[T any](hooks []func(T) T, value T) T {
+	for _, hook := range hooks {
+		value = hook(value)
+	}
+	return value
+}
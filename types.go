@@ -1,48 +1,91 @@
 package a2s
 
+import (
+	"time"
+)
+
 type ServerInfo struct {
-	Protocol    byte
-	Name        string
-	Map         string
-	Folder      string
-	Game        string
-	AppID       uint16
-	Players     byte
-	MaxPlayers  byte
-	Bots        byte
-	ServerType  byte
-	Environment byte
-	Visibility  byte
-	VAC         byte
-	Version     string
-	GamePort    uint16
-	SteamID     uint64
+	Protocol    byte   `json:"protocol"`
+	Name        string `json:"name"`
+	Map         string `json:"map"`
+	Folder      string `json:"folder"`
+	Game        string `json:"game"`
+	AppID       uint16 `json:"app_id"`
+	Players     byte   `json:"players"`
+	MaxPlayers  byte   `json:"max_players"`
+	Bots        byte   `json:"bots"`
+	ServerType  ServerType  `json:"server_type"`
+	Environment Environment `json:"environment"`
+	Visibility  Visibility  `json:"visibility"`
+	VAC         VACStatus   `json:"vac"`
+	Version     string      `json:"version"`
+	GamePort    uint16 `json:"game_port,omitempty"`
+	SteamID     uint64 `json:"steam_id,string,omitempty"`
 	SourceTV    struct {
-		Port uint16
-		Name string
-	}
-	Keywords []string
-	GameID   uint64
-	EDF      byte
+		Port uint16 `json:"port"`
+		Name string `json:"name"`
+	} `json:"source_tv"`
+	Keywords []string `json:"keywords,omitempty"`
+	GameID   uint64   `json:"game_id,string,omitempty"`
+	EDF      byte     `json:"edf"`
+
+	// The Ship only
+	ShipMode      byte `json:"ship_mode,omitempty"`
+	ShipWitnesses byte `json:"ship_witnesses,omitempty"`
+	ShipDuration  byte `json:"ship_duration,omitempty"`
+
+	// GoldSource only
+	ReportedAddress string   `json:"reported_address,omitempty"`
+	Mod             *ModInfo `json:"mod,omitempty"`
+}
+
+// ModInfo describes the mod a GoldSource server is running, as reported in the
+// obsolete GoldSource A2S_INFO response.
+type ModInfo struct {
+	Link         string `json:"link"`
+	DownloadLink string `json:"download_link"`
+	Version      int32  `json:"version"`
+	Size         int32  `json:"size"`
+	// Type is 0 for single and multiplayer mods, 1 for multiplayer only mods.
+	Type byte `json:"type"`
+	// DLL is 0 if the mod uses its own DLL, 1 if it uses the Half-Life DLL.
+	DLL byte `json:"dll"`
 }
 
 type PlayerInfo struct {
-	Index    byte
-	Name     string
-	Score    int32
-	Duration float32
-	Deaths   int32
-	Money    int32
+	Index    byte    `json:"index"`
+	Name     string  `json:"name"`
+	Score    int32   `json:"score"`
+	Duration float32 `json:"duration"`
+	Deaths   int32   `json:"deaths,omitempty"`
+	Money    int32   `json:"money,omitempty"`
 }
 
 type Rule struct {
-	Name  string
-	Value string
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ServerSnapshot is the combined result of Client.QueryAll.
+type ServerSnapshot struct {
+	Info    *ServerInfo  `json:"info"`
+	Players []PlayerInfo `json:"players"`
+	Rules   Rules        `json:"rules"`
+	// RTT is encoded in JSON as nanoseconds.
+	RTT       time.Duration `json:"rtt"`
+	Timestamp time.Time     `json:"timestamp"`
+	// Checksum is the CRC32 check result of compressed responses, if any.
+	Checksum ChecksumStatus `json:"checksum"`
+	// Key identifies the server across restarts and address changes.
+	Key ServerKey `json:"key"`
+	// Metadata is the metadata of the client that took the snapshot, see
+	// WithMetadata.
+	Metadata map[string]any `json:"metadata,omitempty"`
 }
 
 type ServerFeatures struct {
-	Info    bool
-	Players bool
-	Rules   bool
-	Ping    bool
+	Info    bool `json:"info"`
+	Players bool `json:"players"`
+	Rules   bool `json:"rules"`
+	Ping    bool `json:"ping"`
 }
\ No newline at end of file
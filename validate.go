@@ -0,0 +1,25 @@
+//go:build !js
+
+package a2s
+
+import "fmt"
+
+
+// validators are the checks registered with WithInfoValidator and similar
+// options, run on every parsed response before it is returned.
+type validators struct {
+	info    []func(*ServerInfo) error
+	players []func([]PlayerInfo) error
+	rules   []func(Rules) error
+}
+
+// validate runs the checks in the order they were registered and wraps the
+// first error in ErrValidation.
+func validate[T any](checks []func(T) error, value T) error {
+	for _, check := range checks {
+		if err := check(value); err != nil {
+			return fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+	}
+	return nil
+}
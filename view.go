@@ -0,0 +1,123 @@
+package a2s
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"time"
+)
+
+
+// SnapshotView is a read-only view of a ServerSnapshot, for handing one
+// snapshot to several goroutines: nothing reachable through the view can be
+// modified, so consumers cannot race on the shared players and rules. The
+// With methods derive a changed view, copying only the part that changes and
+// sharing the rest, so enriching a snapshot per consumer stays cheap.
+//
+// The snapshot a view was created from must not be modified afterwards.
+type SnapshotView struct {
+	s *ServerSnapshot
+}
+
+// View returns a read-only view of the snapshot. See SnapshotView.
+func (s *ServerSnapshot) View() SnapshotView {
+	return SnapshotView{s: s}
+}
+
+// Info returns a copy of the server info, or the zero ServerInfo if there is
+// none.
+func (v SnapshotView) Info() ServerInfo {
+	if v.s.Info == nil {
+		return ServerInfo{}
+	}
+	info := *v.s.Info
+	info.Keywords = slices.Clone(info.Keywords)
+	if info.Mod != nil {
+		mod := *info.Mod
+		info.Mod = &mod
+	}
+	return info
+}
+
+// NumPlayers returns the number of players in the snapshot.
+func (v SnapshotView) NumPlayers() int {
+	return len(v.s.Players)
+}
+
+// Players yields copies of the players.
+func (v SnapshotView) Players() iter.Seq[PlayerInfo] {
+	return slices.Values(v.s.Players)
+}
+
+// Rules yields copies of the rules.
+func (v SnapshotView) Rules() iter.Seq[Rule] {
+	return slices.Values(v.s.Rules)
+}
+
+// Rule returns the value of the named rule, see Rules.Get.
+func (v SnapshotView) Rule(name string) (string, bool) {
+	return v.s.Rules.Get(name)
+}
+
+func (v SnapshotView) RTT() time.Duration       { return v.s.RTT }
+func (v SnapshotView) Timestamp() time.Time     { return v.s.Timestamp }
+func (v SnapshotView) Checksum() ChecksumStatus { return v.s.Checksum }
+func (v SnapshotView) Key() ServerKey           { return v.s.Key }
+
+// Metadata returns the metadata value stored under key.
+func (v SnapshotView) Metadata(key string) (any, bool) {
+	value, ok := v.s.Metadata[key]
+	return value, ok
+}
+
+// Snapshot returns a deep copy of the snapshot, which the caller owns.
+func (v SnapshotView) Snapshot() *ServerSnapshot {
+	s := *v.s
+	if s.Info != nil {
+		info := v.Info()
+		s.Info = &info
+	}
+	s.Players = slices.Clone(s.Players)
+	s.Rules = slices.Clone(s.Rules)
+	s.Metadata = maps.Clone(s.Metadata)
+	return &s
+}
+
+
+// WithMetadata returns a view that has value stored under key, copying only
+// the metadata.
+func (v SnapshotView) WithMetadata(key string, value any) SnapshotView {
+	s := *v.s
+	s.Metadata = maps.Clone(s.Metadata)
+	if s.Metadata == nil {
+		s.Metadata = make(map[string]any, 1)
+	}
+	s.Metadata[key] = value
+	return SnapshotView{s: &s}
+}
+
+// WithInfo returns a view whose info was changed by fn, copying only the
+// info. fn gets a copy it may modify freely.
+func (v SnapshotView) WithInfo(fn func(*ServerInfo)) SnapshotView {
+	s := *v.s
+	info := v.Info()
+	fn(&info)
+	s.Info = &info
+	return SnapshotView{s: &s}
+}
+
+// WithPlayers returns a view whose players were changed by fn, copying only
+// the players. fn gets a copy it may modify freely.
+func (v SnapshotView) WithPlayers(fn func([]PlayerInfo) []PlayerInfo) SnapshotView {
+	s := *v.s
+	s.Players = fn(slices.Clone(s.Players))
+	return SnapshotView{s: &s}
+}
+
+// WithRules returns a view whose rules were changed by fn, copying only the
+// rules. fn gets a copy it may modify freely.
+func (v SnapshotView) WithRules(fn func(Rules) Rules) SnapshotView {
+	s := *v.s
+	s.Rules = fn(slices.Clone(s.Rules))
+	return SnapshotView{s: &s}
+}
@@ -0,0 +1,12 @@
+//go:build !a2s_minimal && !tinygo
+
+package a2s
+
+import "encoding/json"
+
+
+// MarshalJSON encodes the snapshot like ServerSnapshot. It is left out of
+// the minimal profile, which does without reflection based encoding.
+func (v SnapshotView) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.s)
+}